@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram_bot_downloader/internal/downloader"
+	"telegram_bot_downloader/internal/platforms"
+	"telegram_bot_downloader/internal/urlx"
+	"telegram_bot_downloader/internal/worker"
+)
+
+/* ================= COMMANDS ================= */
+
+// contentTypeLabels renders the registry's content types for /help.
+var contentTypeLabels = map[string]string{
+	"video":     "video",
+	"image":     "rasm",
+	"carousel":  "karusel",
+	"slideshow": "slayd-shou",
+	"animation": "GIF",
+	"story":     "story/highlight",
+}
+
+// handleCommand answers /start and /help. It reports false for anything else
+// so the text is treated as a message with links.
+//
+// /start <payload> is a deep link (t.me/<bot>?start=<payload>) whose payload is
+// a base64url-encoded media URL. It goes through the same dispatch as a pasted
+// link, so a post downloads right away and a profile asks first; websites and
+// channels can link to "download this in the bot".
+func handleCommand(bot *tgbotapi.BotAPI, dl *downloader.PipelineDownloader, msg *tgbotapi.Message, text string) bool {
+	if !strings.HasPrefix(text, "/") {
+		return false
+	}
+	fields := strings.Fields(text)
+	// "/help@downloaderin123_bot" in groups.
+	cmd, _, _ := strings.Cut(fields[0], "@")
+
+	switch cmd {
+	case "/start":
+		if len(fields) > 1 {
+			if link, ok := decodeStartPayload(fields[1]); ok {
+				log.Printf("[start] deep link chat_id=%d url=%q", msg.Chat.ID, link)
+				handleLink(bot, dl, msg, link)
+				return true
+			}
+			log.Printf("[start] bad payload chat_id=%d payload=%q", msg.Chat.ID, fields[1])
+		}
+		reply(bot, msg.Chat.ID, startText(dl.Registry))
+		return true
+	case "/help":
+		reply(bot, msg.Chat.ID, helpText(dl.Registry))
+		return true
+	case "/breakers":
+		if msg.From == nil || !isAdmin(msg.From.ID) {
+			return false
+		}
+		reply(bot, msg.Chat.ID, breakersText())
+		return true
+	}
+	return false
+}
+
+// isAdmin reports whether a Telegram user ID is listed in ADMIN_IDS
+// (comma-separated).
+func isAdmin(userID int64) bool {
+	for _, f := range strings.Split(os.Getenv("ADMIN_IDS"), ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(f), 10, 64); err == nil && id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// breakersText renders engineBreakers for admins.
+func breakersText() string {
+	statuses := engineBreakers.Snapshot()
+	if len(statuses) == 0 {
+		return "Breakers: no engine runs recorded yet."
+	}
+	var b strings.Builder
+	for _, st := range statuses {
+		icon := "🟢"
+		switch st.State {
+		case worker.BreakerOpen:
+			icon = "🔴"
+		case worker.BreakerHalfOpen:
+			icon = "🟡"
+		}
+		fmt.Fprintf(&b, "%s %s — %s, %d/%d ok", icon, st.Key, st.State, st.RecentSuccesses, st.RecentTotal)
+		if st.ConsecutiveFailures > 0 {
+			fmt.Fprintf(&b, ", %d fails in a row", st.ConsecutiveFailures)
+		}
+		if !st.RetryAt.IsZero() {
+			fmt.Fprintf(&b, ", retry %s", st.RetryAt.Format("15:04:05"))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func reply(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	if _, err := bot.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		log.Printf("[send] chat_id=%d err=%v", chatID, err)
+	}
+}
+
+func startText(reg platforms.Registry) string {
+	var labels []string
+	for _, p := range reg.Platforms() {
+		labels = append(labels, p.Label)
+	}
+	return "👋 Salom!\n\n" + strings.Join(labels, ", ") + " link yuboring.\n" +
+		"Video va rasmlarni eng mos va ochiladigan formatda yuklab beraman 🚀\n\n" +
+		"Batafsil: /help"
+}
+
+// helpText is generated from the registry so it can't drift from the engines
+// actually wired up.
+func helpText(reg platforms.Registry) string {
+	var b strings.Builder
+	b.WriteString("ℹ️ Qo‘llab-quvvatlanadigan platformalar:\n\n")
+	for _, p := range reg.Platforms() {
+		var types []string
+		for _, t := range p.ContentTypes {
+			if l, ok := contentTypeLabels[t]; ok {
+				t = l
+			}
+			types = append(types, t)
+		}
+		fmt.Fprintf(&b, "• %s", p.Label)
+		if len(types) > 0 {
+			fmt.Fprintf(&b, " — %s", strings.Join(types, ", "))
+		}
+		if p.Cookies {
+			b.WriteString(" 🍪")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n🍪 — login cookie yuklangan (yopiq kontent ham ochilishi mumkin).\n")
+	b.WriteString("\n📂 Profil linki (instagram.com/<user>/, tiktok.com/@user, x.com/<user>/media) — oxirgi postlarini birdaniga yuklab beraman.\n")
+	b.WriteString("\nLinkni shunchaki xabar qilib yuboring.")
+	return b.String()
+}
+
+// decodeStartPayload turns a /start payload back into a supported media URL.
+// Telegram limits payloads to 64 chars of [A-Za-z0-9_-], which is exactly the
+// base64url alphabet; padding is optional.
+func decodeStartPayload(payload string) (string, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(payload, "="))
+	if err != nil {
+		return "", false
+	}
+	links := extractLinks(string(raw))
+	if len(links) != 1 || urlx.PlatformFromURL(links[0]) == "youtube" {
+		return "", false
+	}
+	return links[0], true
+}
@@ -1,52 +1,466 @@
 package cache
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram_bot_downloader/internal/model"
 )
 
+// FileCache keeps downloaded files on disk under Root/<key>/, bounded by a byte
+// budget (MaxBytes) and an entry age (MaxAge). Entries are evicted least
+// recently used first, in the background; an entry being read (see Checkout)
+// is never evicted. An in-memory index, built once from Root at startup, means
+// Has never lists a directory. Access times are mirrored onto each entry's
+// directory mtime so the LRU order survives restarts.
+//
+// Writes are atomic: Save stages the files in a temp dir under Root, fsyncs
+// them with a manifest (names, sizes, SHA-256, post order and positions), and
+// renames the dir into place. Entries whose manifest doesn't match the disk are
+// misses and get removed. SaveAsync does the same off the caller's path.
 type FileCache struct {
-	Root string // e.g. downloads/cache
+	Root     string        // e.g. downloads/cache
+	MaxBytes int64         // total size budget; 0 = unbounded
+	MaxAge   time.Duration // entries older than this are dropped; 0 = no limit
+
+	mu      sync.Mutex
+	loaded  bool
+	entries map[string]*entry
+	size    int64
+	kick    chan struct{}
+}
+
+type entry struct {
+	m        manifest
+	files    []string // in manifest order, absolute within Root
+	size     int64
+	created  time.Time
+	accessed time.Time
+	pins     int  // readers / writers currently using the entry
+	verified bool // checksums checked since load (sizes are checked on every read)
+}
+
+const (
+	// trashPrefix marks a directory an eviction moved out of the way; it is
+	// deleted outside the lock (and at startup, if a crash left one behind).
+	trashPrefix = ".trash-"
+	// stagingPrefix marks a Save in progress; leftovers are crash debris.
+	stagingPrefix = ".tmp-"
+	// holdPrefix marks the links SaveAsync keeps until its Save has run.
+	holdPrefix = ".hold-"
+)
+
+// New returns a cache rooted at root and loads its index from disk.
+func New(root string, maxBytes int64, maxAge time.Duration) *FileCache {
+	c := &FileCache{Root: root, MaxBytes: maxBytes, MaxAge: maxAge}
+	c.mu.Lock()
+	c.load()
+	c.mu.Unlock()
+	return c
 }
 
-func (c FileCache) CacheDir(key string) string {
+func (c *FileCache) CacheDir(key string) string {
 	return filepath.Join(c.Root, key)
 }
 
-func (c FileCache) Has(key string) (files []string, ok bool) {
-	dir := c.CacheDir(key)
-	entries, err := os.ReadDir(dir)
+// load builds the index from the manifests under Root, removing staging /
+// trash leftovers and entries without a readable manifest. Checksums are
+// verified lazily, on first read. Caller holds c.mu.
+func (c *FileCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	c.entries = make(map[string]*entry)
+	c.size = 0
+
+	dirs, err := os.ReadDir(c.Root)
 	if err != nil {
-		return nil, false
+		return
 	}
-	for _, e := range entries {
-		if e.IsDir() {
+	for _, d := range dirs {
+		dir := filepath.Join(c.Root, d.Name())
+		if !d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			// .tmp-* / .trash-* (or stray files) left behind by a crash.
+			_ = os.RemoveAll(dir)
+			continue
+		}
+		m, err := readManifest(dir)
+		if err != nil {
+			_ = os.RemoveAll(dir)
 			continue
 		}
-		files = append(files, filepath.Join(dir, e.Name()))
+		st, err := os.Stat(dir)
+		if err != nil {
+			continue
+		}
+		c.entries[d.Name()] = &entry{m: m, files: m.paths(dir), size: m.size(), created: m.Created, accessed: st.ModTime()}
+		c.size += m.size()
+	}
+}
+
+func (c *FileCache) expired(e *entry, now time.Time) bool {
+	return c.MaxAge > 0 && now.Sub(e.created) > c.MaxAge
+}
+
+// usable reports whether e can be served: written completely and not expired.
+func (c *FileCache) usable(e *entry, now time.Time) bool {
+	return len(e.files) > 0 && !c.expired(e, now)
+}
+
+// acquire pins key's entry after checking it against its manifest (sizes;
+// checksums too the first time after a restart). A mismatching entry is
+// dropped and reported as a miss. The caller must unpin.
+func (c *FileCache) acquire(key string) (*entry, bool) {
+	c.mu.Lock()
+	c.load()
+	e, ok := c.entries[key]
+	now := time.Now()
+	if !ok || !c.usable(e, now) {
+		c.mu.Unlock()
+		return nil, false
+	}
+	e.pins++
+	e.accessed = now
+	full := !e.verified
+	c.mu.Unlock()
+
+	if err := e.m.verify(c.CacheDir(key), full); err != nil {
+		log.Printf("[cache] corrupt entry key=%s err=%v", key, err)
+		c.unpin(key)
+		c.drop(key, e)
+		return nil, false
+	}
+	c.mu.Lock()
+	e.verified = true
+	c.mu.Unlock()
+	_ = os.Chtimes(c.CacheDir(key), now, now)
+	return e, true
+}
+
+// drop removes e (if it is still key's entry) from the index and disk.
+func (c *FileCache) drop(key string, e *entry) {
+	c.mu.Lock()
+	if c.entries[key] != e || e.pins > 0 {
+		c.mu.Unlock()
+		return
+	}
+	delete(c.entries, key)
+	c.size -= e.size
+	t := c.trashLocked(key)
+	c.mu.Unlock()
+	_ = os.RemoveAll(t)
+}
+
+// trashLocked renames key's dir out of the way and returns the new path ("" if
+// there was nothing to move). Caller holds c.mu.
+func (c *FileCache) trashLocked(key string) string {
+	t := filepath.Join(c.Root, fmt.Sprintf("%s%d-%s", trashPrefix, time.Now().UnixNano(), key))
+	if err := os.Rename(c.CacheDir(key), t); err != nil {
+		return ""
+	}
+	return t
+}
+
+// Has reports whether key is cached and returns its files. Only entries that
+// match their manifest count. The files may be evicted at any time after Has
+// returns; callers that read them should use Checkout instead.
+func (c *FileCache) Has(key string) (files []string, ok bool) {
+	e, ok := c.acquire(key)
+	if !ok {
+		return nil, false
+	}
+	defer c.unpin(key)
+	return append([]string(nil), e.files...), true
+}
+
+// Checkout links (or copies) the cached files for key into dstDir and returns
+// them in the saved order, with the positions and engine metadata Save was
+// given. The entry is pinned meanwhile, so eviction can't delete it half way;
+// the returned files are the caller's and outlive any eviction.
+func (c *FileCache) Checkout(key, dstDir string) ([]model.MediaFile, bool) {
+	e, ok := c.acquire(key)
+	if !ok {
+		return nil, false
+	}
+	defer c.unpin(key)
+
+	out := make([]model.MediaFile, 0, len(e.files))
+	for i, f := range e.files {
+		dst := filepath.Join(dstDir, filepath.Base(f))
+		if err := copyFile(dst, f); err != nil {
+			for _, o := range out {
+				_ = os.Remove(o.Path)
+			}
+			return nil, false
+		}
+		out = append(out, e.m.Files[i].item(dst))
 	}
-	sort.Strings(files)
-	return files, len(files) > 0
+	return out, true
 }
 
-func (c FileCache) Save(key string, srcFiles []string) ([]string, error) {
+func (c *FileCache) unpin(key string) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && e.pins > 0 {
+		e.pins--
+	}
+	c.mu.Unlock()
+}
+
+// errSaveInProgress is returned by a Save that finds another Save of the same
+// key still staging: the caller's files stay uncached (a miss), and the other
+// writer's entry is the one kept.
+var errSaveInProgress = errors.New("cache: entry is being saved by another job")
+
+// Save stores items' files under key atomically: a reader sees either the old
+// entry, the complete new one, or nothing, never a partial copy. When two
+// jobs save the same key at once, the first one's entry is kept.
+func (c *FileCache) Save(key string, items []model.MediaFile) ([]string, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("cache: nothing to save")
+	}
+	c.mu.Lock()
+	c.load()
+	if old, ok := c.entries[key]; ok && old.pins > 0 {
+		c.mu.Unlock()
+		if len(old.files) == 0 {
+			// A placeholder: the other writer hasn't finished staging.
+			return nil, errSaveInProgress
+		}
+		// Someone is reading this entry; its copy is as good as ours.
+		return append([]string(nil), old.files...), nil
+	}
+	// Pin a placeholder so eviction and other Saves leave the key alone while
+	// we stage.
+	placeholder := &entry{pins: 1}
+	prev := c.entries[key]
+	c.entries[key] = placeholder
+	c.mu.Unlock()
+
+	out, err := c.save(key, items, placeholder, prev)
+	if err != nil {
+		c.mu.Lock()
+		if c.entries[key] == placeholder {
+			if prev != nil {
+				c.entries[key] = prev
+			} else {
+				delete(c.entries, key)
+			}
+		}
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.signal()
+	return out, nil
+}
+
+func (c *FileCache) save(key string, items []model.MediaFile, placeholder, prev *entry) ([]string, error) {
+	if err := os.MkdirAll(c.Root, 0755); err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(c.Root, stagingPrefix+key+"-")
+	if err != nil {
+		return nil, err
+	}
+	m, err := stage(staging, items)
+	if err != nil {
+		_ = os.RemoveAll(staging)
+		return nil, err
+	}
+
 	dir := c.CacheDir(key)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	c.mu.Lock()
+	var trash string
+	if prev != nil {
+		c.size -= prev.size
+	}
+	// A replaced (or unindexed, corrupt) dir is moved aside first: rename
+	// can't replace a non-empty directory.
+	trash = c.trashLocked(key)
+	if err := os.Rename(staging, dir); err != nil {
+		delete(c.entries, key)
+		c.mu.Unlock()
+		_ = os.RemoveAll(staging)
+		if trash != "" {
+			_ = os.RemoveAll(trash)
+		}
 		return nil, err
 	}
+	now := time.Now()
+	e := &entry{m: m, files: m.paths(dir), size: m.size(), created: m.Created, accessed: now, verified: true}
+	if c.entries[key] == placeholder {
+		c.entries[key] = e
+		c.size += e.size
+	}
+	c.mu.Unlock()
+
+	_ = syncPath(c.Root)
+	if trash != "" {
+		_ = os.RemoveAll(trash)
+	}
+	return append([]string(nil), e.files...), nil
+}
 
-	var out []string
-	for _, src := range srcFiles {
-		dst := filepath.Join(dir, filepath.Base(src))
-		if err := copyFile(dst, src); err != nil {
-			return nil, err
+// SaveAsync runs Save in the background, so copying, fsyncing and hashing
+// don't hold up the caller. The files are first hardlinked aside (a copy when
+// Root is on another filesystem), so the caller may delete its job dir right
+// away. done, if not nil, gets Save's error from the background goroutine.
+func (c *FileCache) SaveAsync(key string, items []model.MediaFile, done func(error)) {
+	report := func(err error) {
+		if done != nil {
+			done(err)
 		}
-		out = append(out, dst)
 	}
-	sort.Strings(out)
-	return out, nil
+	if err := os.MkdirAll(c.Root, 0755); err != nil {
+		report(err)
+		return
+	}
+	hold, err := os.MkdirTemp(c.Root, holdPrefix+key+"-")
+	if err != nil {
+		report(err)
+		return
+	}
+	held := make([]model.MediaFile, len(items))
+	for i, it := range items {
+		// One subdir per item keeps the base names Save will use.
+		sub := filepath.Join(hold, fmt.Sprint(i))
+		held[i] = it
+		held[i].Path = filepath.Join(sub, filepath.Base(it.Path))
+		err := os.Mkdir(sub, 0755)
+		if err == nil {
+			err = copyFile(held[i].Path, it.Path)
+		}
+		if err != nil {
+			_ = os.RemoveAll(hold)
+			report(err)
+			return
+		}
+	}
+	go func() {
+		defer os.RemoveAll(hold)
+		_, err := c.Save(key, held)
+		report(err)
+	}()
+}
+
+// signal nudges the background evictor (if running) after the cache grew.
+func (c *FileCache) signal() {
+	c.mu.Lock()
+	kick := c.kick
+	c.mu.Unlock()
+	if kick == nil {
+		return
+	}
+	select {
+	case kick <- struct{}{}:
+	default:
+	}
+}
+
+// Evict drops expired entries, then least recently used ones until the cache
+// fits MaxBytes. Pinned entries are skipped. Returns how many were removed.
+func (c *FileCache) Evict() int {
+	now := time.Now()
+
+	c.mu.Lock()
+	c.load()
+	type cand struct {
+		key string
+		e   *entry
+	}
+	var lru []cand
+	var victims []string
+	for key, e := range c.entries {
+		if e.pins > 0 {
+			continue
+		}
+		if c.expired(e, now) {
+			victims = append(victims, key)
+			continue
+		}
+		lru = append(lru, cand{key, e})
+	}
+	size := c.size
+	for _, key := range victims {
+		size -= c.entries[key].size
+	}
+	if c.MaxBytes > 0 && size > c.MaxBytes {
+		sort.Slice(lru, func(i, j int) bool { return lru[i].e.accessed.Before(lru[j].e.accessed) })
+		for _, cd := range lru {
+			if size <= c.MaxBytes {
+				break
+			}
+			victims = append(victims, cd.key)
+			size -= cd.e.size
+		}
+	}
+	// Move victims out of the way under the lock (a rename is cheap), delete
+	// them after: a concurrent Save of the same key then starts from scratch.
+	var trash []string
+	for _, key := range victims {
+		e := c.entries[key]
+		delete(c.entries, key)
+		c.size -= e.size
+		if t := c.trashLocked(key); t != "" {
+			trash = append(trash, t)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, t := range trash {
+		_ = os.RemoveAll(t)
+	}
+	return len(victims)
+}
+
+// StartEvictor runs Evict every interval and whenever Save grows the cache,
+// until the returned stop func is called.
+func (c *FileCache) StartEvictor(interval time.Duration) func() {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	kick := make(chan struct{}, 1)
+	c.mu.Lock()
+	c.kick = kick
+	c.mu.Unlock()
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+			case <-kick:
+			}
+			if n := c.Evict(); n > 0 {
+				log.Printf("[cache] evicted=%d size=%d", n, c.Size())
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// Size returns the indexed total size in bytes.
+func (c *FileCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
 }
 
 func copyFile(dst, src string) error {
@@ -56,7 +470,11 @@ func copyFile(dst, src string) error {
 	if err := os.Link(src, dst); err == nil {
 		return nil
 	}
+	return copyContents(dst, src)
+}
 
+// copyContents copies src into a new file at dst (never a hardlink).
+func copyContents(dst, src string) error {
 	in, err := os.Open(src)
 	if err != nil {
 		return err
@@ -74,4 +492,3 @@ func copyFile(dst, src string) error {
 	}
 	return out.Close()
 }
-
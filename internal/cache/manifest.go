@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"telegram_bot_downloader/internal/model"
+)
+
+// manifestName is the file, inside each entry dir, listing what a complete
+// entry contains. An entry without a matching manifest is treated as garbage.
+const manifestName = "manifest.json"
+
+// manifest lists an entry's files in result order (the post's order), with
+// what the engine knew about each one that the file itself can't tell again.
+type manifest struct {
+	Created time.Time      `json:"created"`
+	Files   []manifestFile `json:"files"`
+}
+
+type manifestFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+
+	Position  int     `json:"position,omitempty"`
+	Animation bool    `json:"animation,omitempty"`
+	Width     int     `json:"width,omitempty"`
+	Height    int     `json:"height,omitempty"`
+	Duration  float64 `json:"duration,omitempty"`
+	SourceURL string  `json:"source_url,omitempty"`
+}
+
+// item returns the engine-provided fields of f for the file at path.
+func (f manifestFile) item(path string) model.MediaFile {
+	return model.MediaFile{
+		Path: path, Position: f.Position, Animation: f.Animation,
+		Width: f.Width, Height: f.Height, Duration: f.Duration, SourceURL: f.SourceURL,
+	}
+}
+
+func (m manifest) size() int64 {
+	var n int64
+	for _, f := range m.Files {
+		n += f.Size
+	}
+	return n
+}
+
+func (m manifest) paths(dir string) []string {
+	out := make([]string, 0, len(m.Files))
+	for _, f := range m.Files {
+		out = append(out, filepath.Join(dir, f.Name))
+	}
+	return out
+}
+
+func readManifest(dir string) (manifest, error) {
+	var m manifest
+	raw, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return m, err
+	}
+	if len(m.Files) == 0 {
+		return m, fmt.Errorf("empty manifest")
+	}
+	return m, nil
+}
+
+// verify checks the files on disk against the manifest: sizes always, and
+// content hashes when full is set.
+func (m manifest) verify(dir string, full bool) error {
+	for _, f := range m.Files {
+		p := filepath.Join(dir, f.Name)
+		st, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if st.Size() != f.Size {
+			return fmt.Errorf("%s: size %d, manifest says %d", f.Name, st.Size(), f.Size)
+		}
+		if full {
+			sum, err := fileSHA256(p)
+			if err != nil {
+				return err
+			}
+			if sum != f.SHA256 {
+				return fmt.Errorf("%s: checksum mismatch", f.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// stage copies items' files into dir, fsyncs them, and writes + fsyncs the
+// manifest and the directory itself, so that once dir is renamed into place
+// the entry is complete even across a crash. The files are real copies, not
+// hardlinks: a job rewriting its file in place must not change the entry
+// behind its checksum. Base names that collide get a numeric suffix; the
+// manifest keeps the items' order.
+func stage(dir string, items []model.MediaFile) (manifest, error) {
+	m := manifest{Created: time.Now()}
+	used := map[string]bool{manifestName: true}
+	for _, it := range items {
+		src := it.Path
+		name := filepath.Base(src)
+		if used[name] {
+			ext := filepath.Ext(name)
+			base := strings.TrimSuffix(name, ext)
+			for n := 2; used[name]; n++ {
+				name = fmt.Sprintf("%s_%d%s", base, n, ext)
+			}
+		}
+		used[name] = true
+		dst := filepath.Join(dir, name)
+		if err := copyContents(dst, src); err != nil {
+			return m, err
+		}
+		if err := syncPath(dst); err != nil {
+			return m, err
+		}
+		st, err := os.Stat(dst)
+		if err != nil {
+			return m, err
+		}
+		sum, err := fileSHA256(dst)
+		if err != nil {
+			return m, err
+		}
+		m.Files = append(m.Files, manifestFile{
+			Name: name, Size: st.Size(), SHA256: sum,
+			Position: it.Position, Animation: it.Animation,
+			Width: it.Width, Height: it.Height, Duration: it.Duration, SourceURL: it.SourceURL,
+		})
+	}
+
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return m, err
+	}
+	mp := filepath.Join(dir, manifestName)
+	if err := os.WriteFile(mp, raw, 0644); err != nil {
+		return m, err
+	}
+	if err := syncPath(mp); err != nil {
+		return m, err
+	}
+	return m, syncPath(dir)
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// syncPath fsyncs a file or directory.
+func syncPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
@@ -0,0 +1,70 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+
+	"telegram_bot_downloader/internal/platforms"
+)
+
+func breakerKey(platform, engine string) string { return platform + "/" + engine }
+
+// allowedEngines drops engines whose breaker is open for this platform — e.g.
+// instagram-native after Instagram rotates its doc_id, or instaloader during a
+// 403 wave — so jobs stop paying for doomed attempts. If every engine is
+// tripped the full list runs anyway (forced): a slow answer beats a guaranteed
+// failure.
+func (p *PipelineDownloader) allowedEngines(ctx context.Context, platform string, engines []platforms.Engine) (allowed []platforms.Engine, forced bool) {
+	if p.Breakers == nil {
+		return engines, false
+	}
+	for _, e := range engines {
+		if p.Breakers.Ready(breakerKey(platform, e.Name())) {
+			allowed = append(allowed, e)
+			continue
+		}
+		p.logfCtx(ctx, "[breaker] skip engine=%s platform=%s (open)", e.Name(), platform)
+	}
+	if len(allowed) == 0 && len(engines) > 0 {
+		p.logfCtx(ctx, "[breaker] every engine open for platform=%s; trying all", platform)
+		return engines, true
+	}
+	return allowed, false
+}
+
+// claimBreaker is called right before an engine runs. It claims the half-open
+// probe slot when the engine is due one, and reports false if another job
+// claimed it first (the engine is then skipped).
+func (p *PipelineDownloader) claimBreaker(platform, engine string) bool {
+	if p.Breakers == nil {
+		return true
+	}
+	return p.Breakers.Allow(breakerKey(platform, engine))
+}
+
+// recordBreaker feeds an engine's outcome to its breaker. A run cut short by
+// the job's own context says nothing about the engine, so it is not counted.
+func (p *PipelineDownloader) recordBreaker(ctx context.Context, platform, engine string, err error) {
+	if p.Breakers == nil {
+		return
+	}
+	key := breakerKey(platform, engine)
+	if err != nil && ctx.Err() != nil {
+		p.Breakers.Release(key)
+		return
+	}
+	if errors.Is(err, platforms.ErrNoMedia) || errors.Is(err, platforms.ErrLoginRequired) || platforms.HandoffTarget(err) != "" {
+		// The engine worked; the post has nothing to fetch, needs a login or
+		// belongs to another engine.
+		err = nil
+	}
+	p.Breakers.Record(key, err)
+}
+
+// releaseBreaker gives back a probe claimed for an engine run that produced no
+// outcome of its own (e.g. a hedged loser we cancelled).
+func (p *PipelineDownloader) releaseBreaker(platform, engine string) {
+	if p.Breakers != nil {
+		p.Breakers.Release(breakerKey(platform, engine))
+	}
+}
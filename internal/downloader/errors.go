@@ -1,10 +1,21 @@
 package downloader
 
-import "errors"
+import (
+	"errors"
+
+	"telegram_bot_downloader/internal/platforms"
+)
 
 var (
 	ErrUnsupported = errors.New("unsupported url")
 	ErrPrivate     = errors.New("private or login-required content")
 	ErrNotFound    = errors.New("content not found")
+
+	// ErrLoginRequired: the content needs a logged-in session the bot
+	// doesn't have (engines wrap platforms.ErrLoginRequired).
+	ErrLoginRequired = platforms.ErrLoginRequired
+
+	// ErrNoMedia: the post has no photo/video (text-only).
+	ErrNoMedia = platforms.ErrNoMedia
 )
 
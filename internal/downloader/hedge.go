@@ -0,0 +1,177 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"telegram_bot_downloader/internal/platforms"
+)
+
+// minHedgeBudget keeps a very fast p90 from starting a second engine on
+// nearly every job.
+const minHedgeBudget = 500 * time.Millisecond
+
+// hedgeBudget is how long engine may run alone before the next one is started
+// next to it: its observed p90, else the strategy's default. Zero = the
+// strategy doesn't hedge.
+func (p *PipelineDownloader) hedgeBudget(platform, mediaType string, strat platforms.Strategy, engine string) time.Duration {
+	h, ok := strat.(platforms.HedgingStrategy)
+	if !ok || h.HedgeBudget() <= 0 {
+		return 0
+	}
+	budget := h.HedgeBudget()
+	if p.Scores != nil {
+		if p90, ok := p.Scores.Latency(platform, mediaType, engine, 0.9); ok {
+			budget = p90
+		}
+	}
+	return max(budget, minHedgeBudget)
+}
+
+type hedgeRun struct {
+	engine  string
+	dir     string
+	res     *DownloadResult
+	err     error
+	took    time.Duration
+	aborted bool // cancelled by us (a winner was found), not a real outcome
+}
+
+// runHedged runs engines in order like the sequential loop, except that an
+// engine still running after its budget gets the next engine started in
+// parallel. Each attempt works in its own sub-directory of jobDir; the first
+// complete result wins, the losers are cancelled and their partial files
+// deleted; ErrNoMedia from any engine ends the race the same way. Returns the
+// winner's engine name.
+func (p *PipelineDownloader) runHedged(ctx context.Context, u, jobDir, platform, mediaType string, strat platforms.Strategy, engines []platforms.Engine, forced bool, optsMatrix []platforms.Options, attempts *attemptLog) (*DownloadResult, string, error) {
+	hctx, cancelAll := context.WithCancel(ctx)
+	defer cancelAll()
+
+	results := make(chan hedgeRun, len(engines))
+	cancels := map[string]context.CancelFunc{}
+	var dirs []string
+	running, next := 0, 0
+
+	// launch starts the next engine the breakers allow; false when none is left.
+	launch := func() bool {
+		for next < len(engines) && ctx.Err() == nil {
+			engine := engines[next]
+			name := engine.Name()
+			dir := filepath.Join(jobDir, fmt.Sprintf("hedge_%d", next))
+			next++
+			if !forced && !p.claimBreaker(platform, name) {
+				p.logfCtx(ctx, "[breaker] skip engine=%s platform=%s (probe in flight)", name, platform)
+				continue
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				p.releaseBreaker(platform, name) // didn't run; don't hold a probe
+				continue
+			}
+			actx, cancel := context.WithCancel(hctx)
+			cancels[name] = cancel
+			dirs = append(dirs, dir)
+			running++
+			go func() {
+				res, took, err := p.runEngine(actx, u, dir, platform, engine, optsMatrix, attempts)
+				results <- hedgeRun{engine: name, dir: dir, res: res, err: err, took: took, aborted: err != nil && actx.Err() != nil && ctx.Err() == nil}
+			}()
+			return true
+		}
+		return false
+	}
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	armTimer := func() {
+		timer.Stop()
+		if next < len(engines) {
+			timer.Reset(p.hedgeBudget(platform, mediaType, strat, engines[next-1].Name()))
+		}
+	}
+
+	if !launch() {
+		return nil, "", fmt.Errorf("download failed: no engine allowed")
+	}
+	armTimer()
+
+	var lastErr error
+	for running > 0 {
+		select {
+		case r := <-results:
+			running--
+			cancels[r.engine]()
+			p.recordBreaker(ctx, platform, r.engine, r.err)
+			p.observe(ctx, platform, mediaType, r.engine, r.err, r.took)
+			if r.err == nil {
+				p.logfCtx(ctx, "[hedge] winner engine=%s took=%s", r.engine, r.took.Truncate(10*time.Millisecond))
+				cancelAll()
+				p.drainHedged(ctx, results, running, platform, mediaType)
+				for _, d := range dirs {
+					if d != r.dir {
+						_ = os.RemoveAll(d)
+					}
+				}
+				return r.res, r.engine, nil
+			}
+			lastErr = r.err
+			_ = os.RemoveAll(r.dir)
+			if errors.Is(r.err, platforms.ErrNoMedia) {
+				// A definitive answer, as in the sequential loop: the engines
+				// still running would find nothing either.
+				cancelAll()
+				p.drainHedged(ctx, results, running, platform, mediaType)
+				for _, d := range dirs {
+					_ = os.RemoveAll(d)
+				}
+				return nil, "", r.err
+			}
+			if ctx.Err() != nil {
+				continue
+			}
+			// A handoff skips the engines before its target, as in the
+			// sequential loop.
+			if target := platforms.HandoffTarget(r.err); target != "" {
+				if j := slices.IndexFunc(engines, func(e platforms.Engine) bool { return e.Name() == target }); j >= next {
+					next = j
+				}
+			}
+			// A failure hands over immediately, like the sequential loop.
+			if running == 0 && launch() {
+				armTimer()
+			}
+		case <-timer.C:
+			p.logfCtx(ctx, "[hedge] budget exceeded; starting next engine alongside")
+			if launch() {
+				armTimer()
+			}
+		}
+	}
+	if lastErr == nil {
+		lastErr = ctx.Err()
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("download failed")
+	}
+	return nil, "", lastErr
+}
+
+// drainHedged waits for the cancelled losers to exit (so their files can be
+// deleted safely) and releases any breaker probes they held.
+func (p *PipelineDownloader) drainHedged(ctx context.Context, results <-chan hedgeRun, running int, platform, mediaType string) {
+	for ; running > 0; running-- {
+		r := <-results
+		if r.aborted || r.err != nil {
+			p.releaseBreaker(platform, r.engine)
+			continue
+		}
+		// Finished successfully in the same instant as the winner: still a
+		// valid observation.
+		p.recordBreaker(ctx, platform, r.engine, nil)
+		p.observe(ctx, platform, mediaType, r.engine, nil, r.took)
+	}
+}
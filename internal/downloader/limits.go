@@ -0,0 +1,63 @@
+package downloader
+
+import (
+	"context"
+	"time"
+
+	"telegram_bot_downloader/internal/worker"
+)
+
+// limitSemaphore returns the shared semaphore for key, creating it with n slots
+// on first use. Limits come from static config, so the first n seen is the one.
+func (p *PipelineDownloader) limitSemaphore(key string, n int) *worker.Semaphore {
+	p.limitsMu.Lock()
+	defer p.limitsMu.Unlock()
+	if p.limits == nil {
+		p.limits = make(map[string]*worker.Semaphore)
+	}
+	s, ok := p.limits[key]
+	if !ok {
+		s = worker.NewSemaphore(n)
+		p.limits[key] = s
+	}
+	return s
+}
+
+// acquireSlots takes, in a fixed order (engine, platform, global — so two
+// attempts can never deadlock on each other), every slot one engine attempt
+// needs. The narrowest slot comes first: a job queued behind a tightly limited
+// engine holds nothing another engine could use, and a global slot is only
+// taken once the attempt can actually start. Slots are held only around that
+// attempt's engine.Download.
+func (p *PipelineDownloader) acquireSlots(ctx context.Context, platform, engine string) (release func(), err error) {
+	var held []*worker.Semaphore
+	release = func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			held[i].Release()
+		}
+	}
+
+	sems := make([]*worker.Semaphore, 0, 3)
+	if n := p.Registry.EngineLimit(engine); n > 0 {
+		sems = append(sems, p.limitSemaphore("engine:"+engine, n))
+	}
+	if n := p.Registry.PlatformLimit(platform); n > 0 {
+		sems = append(sems, p.limitSemaphore("platform:"+platform, n))
+	}
+	if p.Semaphore != nil {
+		sems = append(sems, p.Semaphore)
+	}
+
+	start := time.Now()
+	for _, s := range sems {
+		if err := s.AcquireContext(ctx); err != nil {
+			release()
+			return nil, err
+		}
+		held = append(held, s)
+	}
+	if waited := time.Since(start); waited > 100*time.Millisecond {
+		p.logfCtx(ctx, "[limit] platform=%s engine=%s waited=%s", platform, engine, waited.Truncate(10*time.Millisecond))
+	}
+	return release, nil
+}
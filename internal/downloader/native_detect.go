@@ -0,0 +1,296 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram_bot_downloader/internal/model"
+	"telegram_bot_downloader/internal/platforms"
+)
+
+// NativeDetector fills MediaInfo from cheap HTTP lookups instead of a full
+// yt-dlp extraction: first the platform's oEmbed endpoint, then the page's
+// OpenGraph / Twitter-card meta tags. Only when both come back empty does it
+// hand over to Fallback (usually YtDlpDetector).
+type NativeDetector struct {
+	Client  *http.Client  // default: a shared keep-alive client
+	Timeout time.Duration // per lookup (default 4s)
+
+	// FacebookToken is a Graph API app/client token ("app_id|client_token").
+	// Instagram and Facebook only serve oEmbed with one; without it those
+	// platforms go straight to OpenGraph.
+	FacebookToken string
+
+	// Fallback runs when the native chain finds nothing. Nil = no fallback.
+	Fallback Detector
+}
+
+const (
+	nativeDetectUA      = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36"
+	nativeDetectMaxBody = 1 << 20 // meta tags live in <head>; no need for the whole page
+)
+
+var errNoMetadata = errors.New("no metadata found")
+
+var (
+	nativeDetectOnce   sync.Once
+	nativeDetectClient *http.Client
+)
+
+func (d NativeDetector) timeout() time.Duration {
+	if d.Timeout <= 0 {
+		return 4 * time.Second
+	}
+	return d.Timeout
+}
+
+// client returns d.Client, else the detector's shared keep-alive client. Each
+// lookup is bounded by its context (see fetch), not the client.
+func (d NativeDetector) client() *http.Client {
+	if d.Client != nil {
+		return d.Client
+	}
+	nativeDetectOnce.Do(func() {
+		nativeDetectClient = platforms.NewKeepAliveClient(0, false)
+	})
+	return nativeDetectClient
+}
+
+func (d NativeDetector) Detect(ctx context.Context, u string) (*MediaInfo, error) {
+	info, err := d.detectNative(ctx, u)
+	if err == nil {
+		return info, nil
+	}
+	if d.Fallback == nil {
+		return nil, err
+	}
+	return d.Fallback.Detect(ctx, u)
+}
+
+// DetectNative runs only the HTTP chain (no fallback), for callers that want
+// metadata without risking a slow yt-dlp run.
+func (d NativeDetector) DetectNative(ctx context.Context, u string) (*MediaInfo, error) {
+	return d.detectNative(ctx, u)
+}
+
+// DetectOEmbed runs only the oEmbed lookup: one API call, never a fetch of the
+// page itself. Platforms without a usable oEmbed endpoint (Instagram and
+// Facebook without FacebookToken) report errNoMetadata without a request.
+func (d NativeDetector) DetectOEmbed(ctx context.Context, u string) (*MediaInfo, error) {
+	platform := PlatformFromURL(u)
+	info := &model.MediaInfo{Platform: platform, Type: "unknown", WebpageURL: u}
+	endpoint := d.oembedURL(platform, u)
+	if endpoint == "" {
+		return nil, errNoMetadata
+	}
+	if err := d.fromOEmbed(ctx, endpoint, info); err != nil {
+		return nil, fmt.Errorf("oembed: %w", err)
+	}
+	if info.Title == "" && info.Thumbnail == "" && info.Uploader == "" {
+		return nil, errNoMetadata
+	}
+	return info, nil
+}
+
+func (d NativeDetector) detectNative(ctx context.Context, u string) (*MediaInfo, error) {
+	platform := PlatformFromURL(u)
+	info := &model.MediaInfo{Platform: platform, Type: "unknown", WebpageURL: u}
+
+	var errs []error
+	if endpoint := d.oembedURL(platform, u); endpoint != "" {
+		if err := d.fromOEmbed(ctx, endpoint, info); err != nil {
+			errs = append(errs, fmt.Errorf("oembed: %w", err))
+		}
+	}
+	// OpenGraph fills whatever oEmbed left out (oEmbed rarely says "video"
+	// vs "image", and X's has no thumbnail at all).
+	if info.Title == "" || info.Thumbnail == "" || info.Type == "unknown" {
+		if err := d.fromOpenGraph(ctx, u, info); err != nil {
+			errs = append(errs, fmt.Errorf("opengraph: %w", err))
+		}
+	}
+
+	if info.Title == "" && info.Thumbnail == "" && info.Uploader == "" {
+		errs = append(errs, errNoMetadata)
+		return nil, errors.Join(errs...)
+	}
+	if info.Title == "" {
+		info.Title = "media"
+	}
+	return info, nil
+}
+
+// oembedURL returns the platform's oEmbed endpoint for u, or "" when there is
+// none usable.
+func (d NativeDetector) oembedURL(platform, u string) string {
+	q := url.QueryEscape(u)
+	switch platform {
+	case "tiktok":
+		return "https://www.tiktok.com/oembed?url=" + q
+	case "twitter":
+		return "https://publish.twitter.com/oembed?omit_script=1&url=" + q
+	case "pinterest":
+		return "https://www.pinterest.com/oembed.json?url=" + q
+	case "instagram":
+		if d.FacebookToken != "" {
+			return "https://graph.facebook.com/v19.0/instagram_oembed?omitscript=true&url=" + q + "&access_token=" + url.QueryEscape(d.FacebookToken)
+		}
+	case "facebook":
+		if d.FacebookToken != "" {
+			kind := "oembed_post"
+			if l := strings.ToLower(u); strings.Contains(l, "/videos/") || strings.Contains(l, "/reel") || strings.Contains(l, "fb.watch") || strings.Contains(l, "/watch") {
+				kind = "oembed_video"
+			}
+			return "https://graph.facebook.com/v19.0/" + kind + "?omitscript=true&url=" + q + "&access_token=" + url.QueryEscape(d.FacebookToken)
+		}
+	}
+	return ""
+}
+
+type oembedResponse struct {
+	Type         string `json:"type"` // video, photo, rich, link
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	HTML         string `json:"html"`
+}
+
+func (d NativeDetector) fromOEmbed(ctx context.Context, endpoint string, info *model.MediaInfo) error {
+	body, err := d.fetch(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	var o oembedResponse
+	if err := json.Unmarshal(body, &o); err != nil {
+		return err
+	}
+
+	switch o.Type {
+	case "video":
+		// TikTok answers "video" for photo posts too; leave those to the URL
+		// heuristic / OpenGraph.
+		if info.Platform != "tiktok" || !strings.Contains(info.WebpageURL, "/photo/") {
+			info.Type = "video"
+		}
+	case "photo":
+		info.Type = "image"
+	}
+	info.Title = firstNonEmpty(info.Title, o.Title, tweetText(o.HTML))
+	info.Uploader = firstNonEmpty(info.Uploader, o.AuthorName)
+	info.Thumbnail = firstNonEmpty(info.Thumbnail, o.ThumbnailURL)
+	return nil
+}
+
+var (
+	metaTagRe  = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	metaAttrRe = regexp.MustCompile(`(?is)(property|name|content)\s*=\s*("([^"]*)"|'([^']*)')`)
+	tweetPRe   = regexp.MustCompile(`(?is)<p[^>]*>(.*?)</p>`)
+	tagRe      = regexp.MustCompile(`(?s)<[^>]+>`)
+)
+
+func (d NativeDetector) fromOpenGraph(ctx context.Context, u string, info *model.MediaInfo) error {
+	body, err := d.fetch(ctx, u)
+	if err != nil {
+		return err
+	}
+	meta := parseMetaTags(string(body))
+	if len(meta) == 0 {
+		return errNoMetadata
+	}
+
+	info.Title = firstNonEmpty(info.Title, meta["og:title"], meta["twitter:title"], meta["og:description"])
+	info.Uploader = firstNonEmpty(info.Uploader, meta["article:author"], meta["twitter:creator"], meta["og:site_name"])
+	info.Thumbnail = firstNonEmpty(info.Thumbnail, meta["og:image"], meta["og:image:secure_url"], meta["twitter:image"])
+	if info.Type == "unknown" {
+		switch {
+		case meta["og:video"] != "" || meta["og:video:secure_url"] != "" || strings.HasPrefix(meta["og:type"], "video") || meta["twitter:card"] == "player":
+			info.Type = "video"
+		case meta["og:image"] != "" && (meta["og:type"] == "" || meta["og:type"] == "article" || meta["og:type"] == "website"):
+			// Pages without a video tag are usually photo posts; carousels
+			// aren't distinguishable here, so don't guess beyond "image" for
+			// platforms where the URL heuristic already does better.
+			if info.Platform == "pinterest" || info.Platform == "twitter" {
+				info.Type = "image"
+			}
+		}
+	}
+	return nil
+}
+
+// parseMetaTags collects <meta property|name=... content=...> pairs, first one
+// wins (pages often repeat og:image for every size).
+func parseMetaTags(page string) map[string]string {
+	out := map[string]string{}
+	for _, tag := range metaTagRe.FindAllString(page, -1) {
+		var key, content string
+		for _, m := range metaAttrRe.FindAllStringSubmatch(tag, -1) {
+			val := m[3] + m[4]
+			switch strings.ToLower(m[1]) {
+			case "property", "name":
+				key = strings.ToLower(val)
+			case "content":
+				content = html.UnescapeString(val)
+			}
+		}
+		if key == "" || strings.TrimSpace(content) == "" {
+			continue
+		}
+		if _, seen := out[key]; !seen {
+			out[key] = strings.TrimSpace(content)
+		}
+	}
+	return out
+}
+
+// tweetText pulls the tweet text out of X's oEmbed blockquote (it has no title).
+func tweetText(embed string) string {
+	m := tweetPRe.FindStringSubmatch(embed)
+	if m == nil {
+		return ""
+	}
+	return strings.TrimSpace(html.UnescapeString(tagRe.ReplaceAllString(m[1], "")))
+}
+
+func (d NativeDetector) fetch(ctx context.Context, u string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", nativeDetectUA)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	resp, err := d.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, ErrPrivate
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, nativeDetectMaxBody))
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"telegram_bot_downloader/internal/platforms"
+)
+
+// fillMissing asks the engines after the one that produced a partial result
+// (res.Missing non-empty) for just the missing items, and merges what they
+// fetch back into res in post order. Only engines that honour
+// Options.Positions are asked. Whatever is still missing afterwards stays in
+// res.Missing for the sender to mention.
+func (p *PipelineDownloader) fillMissing(ctx context.Context, u, jobDir, platform string, rest []platforms.Engine, forced bool, optsMatrix []platforms.Options, attempts *attemptLog, res *DownloadResult) {
+	for i, engine := range rest {
+		if len(res.Missing) == 0 || ctx.Err() != nil {
+			return
+		}
+		if pe, ok := engine.(platforms.PositionalEngine); !ok || !pe.SupportsPositions() {
+			continue
+		}
+		name := engine.Name()
+		if !forced && !p.claimBreaker(platform, name) {
+			continue
+		}
+		dir := filepath.Join(jobDir, fmt.Sprintf("fill_%d", i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			p.releaseBreaker(platform, name)
+			continue
+		}
+		opts := make([]platforms.Options, len(optsMatrix))
+		for j, o := range optsMatrix {
+			o.Positions = slices.Clone(res.Missing)
+			opts[j] = o
+		}
+		p.logfCtx(ctx, "[partial] engine=%s missing=%v", name, res.Missing)
+		part, _, err := p.runEngine(ctx, u, dir, platform, engine, opts, attempts)
+		// Not observed: a few-item fetch would skew the engine's latency stats.
+		p.recordBreaker(ctx, platform, name, err)
+		if err == nil {
+			mergePartial(res, part, jobDir)
+		}
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// mergePartial moves part's files for positions res is missing into jobDir
+// and re-sorts res by post position. Files for positions res already has are
+// left behind (the caller deletes the fill directory).
+func mergePartial(res, part *DownloadResult, jobDir string) {
+	if len(res.Items) != len(res.Files) {
+		syncItems(res)
+	}
+	for _, item := range part.Items {
+		if !slices.Contains(res.Missing, item.Position) {
+			continue
+		}
+		dst := filepath.Join(jobDir, filepath.Base(item.Path))
+		if _, err := os.Stat(dst); err == nil {
+			dst = filepath.Join(jobDir, fmt.Sprintf("p%02d_%s", item.Position, filepath.Base(item.Path)))
+		}
+		if err := os.Rename(item.Path, dst); err != nil {
+			continue
+		}
+		item.Path = dst
+		res.Items = append(res.Items, item)
+		res.Missing = slices.DeleteFunc(res.Missing, func(pos int) bool { return pos == item.Position })
+	}
+	sort.SliceStable(res.Items, func(i, j int) bool { return res.Items[i].Position < res.Items[j].Position })
+	res.Files = res.Files[:0]
+	for _, item := range res.Items {
+		res.Files = append(res.Files, item.Path)
+	}
+	res.Size = fileTotalSize(res.Files)
+}
@@ -7,10 +7,15 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"telegram_bot_downloader/internal/cache"
+	"telegram_bot_downloader/internal/media"
+	"telegram_bot_downloader/internal/model"
 	"telegram_bot_downloader/internal/platforms"
 	"telegram_bot_downloader/internal/worker"
 )
@@ -25,15 +30,62 @@ func ContextWithJobLogger(ctx context.Context, logf func(format string, args ...
 	return context.WithValue(ctx, jobLogCtxKey{}, logf)
 }
 
+type jobEventsCtxKey struct{}
+
+// JobEvents receives a job's progress from the pipeline (see internal/jobs).
+type JobEvents interface {
+	Detecting()
+	EngineStarted(engine string)
+	EngineFinished(engine string, err error)
+}
+
+// ContextWithJobEvents attaches a per-request progress sink, like ContextWithJobLogger.
+func ContextWithJobEvents(ctx context.Context, ev JobEvents) context.Context {
+	if ev == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, jobEventsCtxKey{}, ev)
+}
+
+func jobEventsFrom(ctx context.Context) JobEvents {
+	if ev, ok := ctx.Value(jobEventsCtxKey{}).(JobEvents); ok {
+		return ev
+	}
+	return nopJobEvents{}
+}
+
+type nopJobEvents struct{}
+
+func (nopJobEvents) Detecting()                   {}
+func (nopJobEvents) EngineStarted(string)         {}
+func (nopJobEvents) EngineFinished(string, error) {}
+
 type PipelineDownloader struct {
-	Detector   YtDlpDetector
-	Registry   platforms.Registry
-	Cache      cache.FileCache
-	Semaphore  *worker.Semaphore
+	Detector  Detector // e.g. NativeDetector{Fallback: YtDlpDetector{}}
+	Registry  platforms.Registry
+	Cache     *cache.FileCache // nil disables the disk cache
+	Semaphore *worker.Semaphore
 
 	DownloadsRoot string // e.g. "downloads"
 	JobTTL        time.Duration
 	Logger        func(format string, args ...any)
+
+	// Breakers, if set, skips engines that keep failing on a platform (see
+	// allowedEngines). Nil disables circuit breaking.
+	Breakers *worker.BreakerSet
+
+	// Scores, if set, learns each engine's success rate and latency and
+	// re-ranks engines for strategies that opt in (platforms.AdaptiveStrategy).
+	Scores *platforms.Scoreboard
+
+	// PostProcess runs, in order, on every successful download before it is
+	// cached and returned (see PostStep for per-platform enablement).
+	PostProcess []PostStep
+
+	// Per-platform / per-engine semaphores (see acquireSlots), created lazily
+	// from Registry.Limits.
+	limitsMu sync.Mutex
+	limits   map[string]*worker.Semaphore
 }
 
 func (p *PipelineDownloader) logf(format string, args ...any) {
@@ -66,21 +118,29 @@ func (p *PipelineDownloader) Download(ctx context.Context, url string, jobDir st
 
 func (p *PipelineDownloader) DownloadWithInfo(ctx context.Context, url string, jobDir string, info *MediaInfo) (*DownloadResult, error) {
 	u := NormalizeURL(url)
-
-	if p.Semaphore != nil {
-		p.Semaphore.Acquire()
-		defer p.Semaphore.Release()
-	}
+	began := time.Now()
 
 	cacheKey := HashURL(u)
-	if p.Cache.Root != "" {
-		if files, ok := p.Cache.Has(cacheKey); ok {
-			return &DownloadResult{Files: files, Size: fileTotalSize(files)}, nil
+	if p.Cache != nil {
+		// Checkout links the entry into jobDir, so eviction can't pull the
+		// files out from under the upload.
+		if items, ok := p.Cache.Checkout(cacheKey, jobDir); ok {
+			res := &DownloadResult{Items: items, Engine: "cache"}
+			for _, it := range items {
+				res.Files = append(res.Files, it.Path)
+			}
+			res.Size = fileTotalSize(res.Files)
+			syncItems(res)
+			res.Elapsed = time.Since(began)
+			return res, nil
 		}
 	}
 
+	events := jobEventsFrom(ctx)
+
 	// Detection failure should not block downloads completely (fallback to URL heuristics).
 	if info == nil {
+		events.Detecting()
 		detected, derr := p.Detector.Detect(ctx, u)
 		if derr != nil {
 			p.logfCtx(ctx, "[detect] url=%s err=%v", u, derr)
@@ -88,6 +148,7 @@ func (p *PipelineDownloader) DownloadWithInfo(ctx context.Context, url string, j
 		info = detected
 	}
 
+	platform := p.Registry.PlatformKey(info, u)
 	strat := p.Registry.StrategyFor(info, u)
 	engines := strat.EnginesFor(info)
 	optsMatrix := strat.OptionsMatrix(u)
@@ -104,48 +165,60 @@ func (p *PipelineDownloader) DownloadWithInfo(ctx context.Context, url string, j
 		p.logfCtx(ctx, "[job] platform=%s type=%s", PlatformFromURL(u), "unknown")
 	}
 
-	var lastErr error
-	for _, engine := range engines {
-		for idx, opts := range optsMatrix {
-			engineName := engine.Name()
-			attemptLabel := idx + 1
-
-			p.logfCtx(ctx, "[download] engine=%s retry=%d url=%s", engineName, attemptLabel, u)
-
-			res, err := engine.Download(ctx, u, jobDir, opts)
-			if err == nil && res != nil && len(res.Files) > 0 {
-				// Cache on success.
-				if p.Cache.Root != "" {
-					if cachedFiles, cerr := p.Cache.Save(cacheKey, res.Files); cerr != nil {
-						// Cache failures should not fail the download itself.
-						p.logfCtx(ctx, "[cache] save_failed key=%s err=%v", cacheKey, cerr)
-					} else if len(cachedFiles) > 0 {
-						p.logfCtx(ctx, "[download] engine=%s status=success cache=hit", engineName)
-					}
-				}
-
-				p.logfCtx(ctx, "[download] engine=%s status=success", engineName)
-				// Prefer cached files if present; otherwise return job dir output.
-				if p.Cache.Root != "" {
-					if files, ok := p.Cache.Has(cacheKey); ok {
-						return &DownloadResult{Files: files, Size: fileTotalSize(files)}, nil
-					}
-				}
-				files := allFilesInDir(jobDir)
-				return &DownloadResult{Files: files, Size: fileTotalSize(files)}, nil
-			}
+	// Engines whose circuit breaker is open are skipped for their cooldown.
+	mediaType := ""
+	if info != nil {
+		mediaType = info.Type
+	}
+	engines = p.rankEngines(ctx, platform, mediaType, strat, engines)
+	engines, forced := p.allowedEngines(ctx, platform, engines)
+	attempts := &attemptLog{}
 
-			if err == nil {
-				err = fmt.Errorf("%s produced empty result", engineName)
-			}
-			lastErr = err
-			p.logfCtx(ctx, "[download] engine=%s status=fail err=%v", engineName, err)
+	// Latency-critical strategies can opt in to hedged (raced) attempts.
+	if len(engines) > 1 && p.hedgeBudget(platform, mediaType, strat, engines[0].Name()) > 0 {
+		res, engineName, err := p.runHedged(ctx, u, jobDir, platform, mediaType, strat, engines, forced, optsMatrix, attempts)
+		if err != nil {
+			return nil, err
+		}
+		if len(res.Missing) > 0 {
+			rest := slices.DeleteFunc(slices.Clone(engines), func(e platforms.Engine) bool { return e.Name() == engineName })
+			p.fillMissing(ctx, u, jobDir, platform, rest, forced, optsMatrix, attempts, res)
+		}
+		return p.complete(ctx, cacheKey, platform, engineName, res, attempts, began), nil
+	}
 
-			// If the engine can't run in this environment, don't waste retries/options.
-			if errors.Is(err, platforms.ErrEngineUnavailable) {
-				break
+	var lastErr error
+	handoff := ""
+	for i, engine := range engines {
+		engineName := engine.Name()
+		if handoff != "" && engineName != handoff {
+			continue
+		}
+		handoff = ""
+		if !forced && !p.claimBreaker(platform, engineName) {
+			p.logfCtx(ctx, "[breaker] skip engine=%s platform=%s (probe in flight)", engineName, platform)
+			continue
+		}
+		res, took, err := p.runEngine(ctx, u, jobDir, platform, engine, optsMatrix, attempts)
+		p.recordBreaker(ctx, platform, engineName, err)
+		p.observe(ctx, platform, mediaType, engineName, err, took)
+		if err == nil {
+			if len(res.Missing) > 0 {
+				p.fillMissing(ctx, u, jobDir, platform, engines[i+1:], forced, optsMatrix, attempts, res)
 			}
+			return p.complete(ctx, cacheKey, platform, engineName, res, attempts, began), nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			// The job timed out / was cancelled: the remaining engines can't run.
+			break
 		}
+		if errors.Is(err, platforms.ErrNoMedia) {
+			// A definitive answer: other engines would find nothing either.
+			break
+		}
+		// The engine recognised the post as another engine's: skip ahead.
+		handoff = platforms.HandoffTarget(err)
 	}
 
 	if lastErr == nil {
@@ -154,6 +227,135 @@ func (p *PipelineDownloader) DownloadWithInfo(ctx context.Context, url string, j
 	return nil, lastErr
 }
 
+// attemptLog collects a download's engine runs (hedged runs add concurrently).
+type attemptLog struct {
+	mu   sync.Mutex
+	list []model.Attempt
+}
+
+func (l *attemptLog) add(engine string, started time.Time, err error) {
+	a := model.Attempt{Engine: engine, StartedAt: started, Duration: time.Since(started)}
+	if err != nil {
+		a.Err = err.Error()
+	}
+	l.mu.Lock()
+	l.list = append(l.list, a)
+	l.mu.Unlock()
+}
+
+func (l *attemptLog) snapshot() []model.Attempt {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := append([]model.Attempt(nil), l.list...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].StartedAt.Before(out[j].StartedAt) })
+	return out
+}
+
+// runEngine runs one engine over the options matrix until an attempt yields
+// files. Slots (see acquireSlots) are held only around each engine.Download.
+// took is the time spent in engine.Download alone: queueing for a slot isn't
+// the engine being slow, so it mustn't feed the latency scores.
+func (p *PipelineDownloader) runEngine(ctx context.Context, u, jobDir, platform string, engine platforms.Engine, optsMatrix []platforms.Options, attempts *attemptLog) (*DownloadResult, time.Duration, error) {
+	engineName := engine.Name()
+	events := jobEventsFrom(ctx)
+
+	var lastErr error
+	var took time.Duration
+	for idx, opts := range optsMatrix {
+		attemptLabel := idx + 1
+
+		p.logfCtx(ctx, "[download] engine=%s retry=%d url=%s", engineName, attemptLabel, u)
+
+		release, err := p.acquireSlots(ctx, platform, engineName)
+		if err != nil {
+			// The job's context is done while waiting for a slot: nothing left to try.
+			return nil, took, err
+		}
+		events.EngineStarted(engineName)
+		started := time.Now()
+		res, err := engine.Download(ctx, u, jobDir, opts)
+		took += time.Since(started)
+		release()
+		if err == nil && (res == nil || len(res.Files) == 0) {
+			err = fmt.Errorf("%s produced empty result", engineName)
+		}
+		if err == nil {
+			// A login wall saved as .jpg or a truncated body is an engine
+			// failure too, so the next option / engine gets its turn.
+			err = p.validateResult(ctx, engine, res)
+		}
+		attempts.add(engineName, started, err)
+		events.EngineFinished(engineName, err)
+		if err == nil {
+			return res, took, nil
+		}
+
+		lastErr = err
+		p.logfCtx(ctx, "[download] engine=%s status=fail err=%v", engineName, err)
+
+		// If the engine can't run in this environment, the post has nothing to
+		// download or belongs to another engine, don't waste retries/options.
+		if errors.Is(err, platforms.ErrEngineUnavailable) || errors.Is(err, platforms.ErrNoMedia) || platforms.HandoffTarget(err) != "" {
+			break
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("%s: no attempts", engineName)
+	}
+	return nil, took, lastErr
+}
+
+// complete turns a successful engine run into the job's result: post-processes
+// it, fills in the per-file and per-run metadata, and hands it to the disk
+// cache (when enabled) to save in the background.
+func (p *PipelineDownloader) complete(ctx context.Context, cacheKey, platform, engineName string, res *DownloadResult, attempts *attemptLog, began time.Time) *DownloadResult {
+	p.postProcess(ctx, platform, res)
+	syncItems(res)
+	res.Engine = engineName
+	res.Attempts = attempts.snapshot()
+
+	// Cache on success. A partial carousel isn't cached, so the next request
+	// gets another chance at the missing items.
+	if len(res.Missing) > 0 {
+		p.logfCtx(ctx, "[download] engine=%s status=partial missing=%v", engineName, res.Missing)
+	} else if p.Cache != nil {
+		// Saved in the background: the copy, fsync and hashing must not hold
+		// up the upload. Cache failures don't fail the download itself.
+		p.Cache.SaveAsync(cacheKey, res.Items, func(err error) {
+			if err != nil {
+				p.logfCtx(ctx, "[cache] save_failed key=%s err=%v", cacheKey, err)
+			}
+		})
+	}
+
+	p.logfCtx(ctx, "[download] engine=%s status=success", engineName)
+	// Serve the job dir copy (res.Files): a cache entry may be evicted while
+	// we upload.
+	res.Elapsed = time.Since(began)
+	return res
+}
+
+// syncItems realigns res.Items with res.Files after post-processing (which
+// keeps the order but may rename or rewrite files): engine-provided fields
+// (post position included) carry over by index, the rest is re-read from the
+// files.
+func syncItems(res *DownloadResult) {
+	items := make([]model.MediaFile, len(res.Files))
+	for i, f := range res.Files {
+		var mf model.MediaFile
+		if i < len(res.Items) {
+			mf = res.Items[i]
+		}
+		mf.Path = f
+		if mf.Position == 0 {
+			mf.Position = i + 1
+		}
+		media.Describe(&mf)
+		items[i] = mf
+	}
+	res.Items = items
+}
+
 func (p *PipelineDownloader) EnsureDirs() error {
 	root := p.DownloadsRoot
 	if root == "" {
@@ -162,7 +364,7 @@ func (p *PipelineDownloader) EnsureDirs() error {
 	if err := os.MkdirAll(root, 0755); err != nil {
 		return err
 	}
-	if p.Cache.Root != "" {
+	if p.Cache != nil && p.Cache.Root != "" {
 		if err := os.MkdirAll(p.Cache.Root, 0755); err != nil {
 			return err
 		}
@@ -175,7 +377,9 @@ func (p *PipelineDownloader) CacheRootDefault() {
 	if root == "" {
 		root = "downloads"
 	}
-	if p.Cache.Root == "" {
+	if p.Cache == nil {
+		p.Cache = cache.New(filepath.Join(root, "cache"), 0, 0)
+	} else if p.Cache.Root == "" {
 		p.Cache.Root = filepath.Join(root, "cache")
 	}
 }
@@ -219,4 +423,3 @@ func sortStrings(s []string) {
 		}
 	}
 }
-
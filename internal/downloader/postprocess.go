@@ -0,0 +1,204 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"telegram_bot_downloader/internal/media"
+)
+
+// PostProcessor transforms a successful download, whatever engine produced it.
+// It may rewrite or rename files but must keep res.Files in sync, position for
+// position (res.Items is realigned by index afterwards), and on error must
+// leave every listed file usable (processors are best effort).
+type PostProcessor interface {
+	Name() string
+	Process(ctx context.Context, platform string, res *DownloadResult) error
+}
+
+// PostStep enables a PostProcessor in PipelineDownloader.PostProcess.
+type PostStep struct {
+	Processor PostProcessor
+	Platforms []string // Registry.PlatformKey values; empty = every platform
+}
+
+// postProcess runs the enabled steps in order. A failing step is logged and
+// skipped; it never fails the download.
+func (p *PipelineDownloader) postProcess(ctx context.Context, platform string, res *DownloadResult) {
+	for _, step := range p.PostProcess {
+		if len(step.Platforms) > 0 && !slices.Contains(step.Platforms, platform) {
+			continue
+		}
+		if err := step.Processor.Process(ctx, platform, res); err != nil {
+			p.logfCtx(ctx, "[post] processor=%s err=%v", step.Processor.Name(), err)
+		}
+	}
+	res.Size = fileTotalSize(res.Files)
+}
+
+// NormalizeFormats identifies each file from its content rather than its
+// extension (engines hardcode .jpg/.mp4 while CDNs increasingly serve
+// WebP/AVIF/HEIC): it fixes a wrong extension and converts still HEIC, AVIF
+// and WebP images to JPEG, which sendPhoto handles well. Animated WebPs are
+// left alone; the sender turns them into animations. Run it first.
+type NormalizeFormats struct{}
+
+func (NormalizeFormats) Name() string { return "normalize-formats" }
+
+func (NormalizeFormats) Process(ctx context.Context, _ string, res *DownloadResult) error {
+	var errs []string
+	for i, f := range res.Files {
+		format, ok := media.Sniff(f)
+		if !ok {
+			continue
+		}
+		if ext := strings.ToLower(filepath.Ext(f)); extFamily(ext) != extFamily(format.Ext) {
+			dst := strings.TrimSuffix(f, filepath.Ext(f)) + format.Ext
+			if _, err := os.Stat(dst); err == nil {
+				errs = append(errs, fmt.Sprintf("%s: %s already exists", filepath.Base(f), filepath.Base(dst)))
+			} else if err := os.Rename(f, dst); err != nil {
+				errs = append(errs, err.Error())
+			} else {
+				f = dst
+				res.Files[i] = f
+			}
+		}
+		switch format.Ext {
+		case ".heic", ".avif", ".webp":
+			if format.Ext == ".webp" && media.IsAnimatedWebP(f) {
+				continue
+			}
+			jpg, err := media.ToJPEG(ctx, f)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", filepath.Base(f), err))
+				continue
+			}
+			_ = os.Remove(f)
+			res.Files[i] = jpg
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// extFamily folds extensions that name the same container, so a .jpeg or an
+// MP4 whose ftyp says QuickTime isn't renamed for nothing.
+func extFamily(ext string) string {
+	switch ext {
+	case ".jpeg", ".jpe":
+		return ".jpg"
+	case ".m4v", ".mov", ".m4a": // DASH audio often carries a generic MP4 ftyp
+		return ".mp4"
+	case ".mkv":
+		return ".webm"
+	case ".opus", ".oga":
+		return ".ogg"
+	}
+	return ext
+}
+
+var videoExts = map[string]bool{".mp4": true, ".m4v": true, ".mov": true, ".webm": true, ".mkv": true}
+
+// StripMetadata removes EXIF/XMP/IPTC from JPEGs (segment strip, no
+// re-encode) and container metadata from videos (ffmpeg stream copy).
+type StripMetadata struct{}
+
+func (StripMetadata) Name() string { return "strip-metadata" }
+
+func (StripMetadata) Process(ctx context.Context, _ string, res *DownloadResult) error {
+	var errs []string
+	for _, f := range res.Files {
+		var err error
+		switch ext := strings.ToLower(filepath.Ext(f)); {
+		case ext == ".jpg" || ext == ".jpeg":
+			_, err = media.StripJPEGMetadata(f)
+		case videoExts[ext]:
+			err = media.StripVideoMetadata(ctx, f)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Faststart remuxes MP4/MOV files with the moov atom up front so Telegram
+// can stream them; files already laid out that way are left untouched.
+type Faststart struct{}
+
+func (Faststart) Name() string { return "faststart" }
+
+func (Faststart) Process(ctx context.Context, _ string, res *DownloadResult) error {
+	var errs []string
+	for _, f := range res.Files {
+		if err := media.Faststart(ctx, f); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// RenameFiles gives downloaded files predictable names (engines use post IDs,
+// yt-dlp titles, CDN names...), which is what users see on documents.
+// Pattern placeholders: {platform}, {n} (the item's 1-based post position, else
+// its place in the result; zero padded so the order is kept), {name} (original
+// name without extension), {ext}.
+type RenameFiles struct {
+	Pattern string // default "{platform}_{n}{ext}"
+}
+
+func (RenameFiles) Name() string { return "rename" }
+
+func (r RenameFiles) Process(_ context.Context, platform string, res *DownloadResult) error {
+	pattern := r.Pattern
+	if pattern == "" {
+		pattern = "{platform}_{n}{ext}"
+	}
+	renamed := make([]string, 0, len(res.Files))
+	for i, f := range res.Files {
+		ext := strings.ToLower(filepath.Ext(f))
+		// A partial or position-filtered carousel keeps the post's numbering.
+		n := i + 1
+		if i < len(res.Items) && res.Items[i].Position > 0 {
+			n = res.Items[i].Position
+		}
+		name := strings.NewReplacer(
+			"{platform}", platform,
+			"{n}", fmt.Sprintf("%02d", n),
+			"{name}", strings.TrimSuffix(filepath.Base(f), filepath.Ext(f)),
+			"{ext}", ext,
+		).Replace(pattern)
+		name = strings.Map(func(r rune) rune {
+			if r == '/' || r == os.PathSeparator || r == 0 {
+				return '_'
+			}
+			return r
+		}, name)
+		dst := filepath.Join(filepath.Dir(f), name)
+		if dst != f {
+			if _, err := os.Stat(dst); err == nil {
+				renamed = append(renamed, f) // don't clobber an existing file
+				continue
+			}
+			if err := os.Rename(f, dst); err != nil {
+				res.Files = append(renamed, res.Files[i:]...)
+				return err
+			}
+		}
+		renamed = append(renamed, dst)
+	}
+	res.Files = renamed
+	return nil
+}
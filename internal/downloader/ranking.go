@@ -0,0 +1,47 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"telegram_bot_downloader/internal/platforms"
+)
+
+// rankEngines re-orders the strategy's engines by observed success and latency
+// when the strategy opts in; otherwise the hand-tuned order stands.
+func (p *PipelineDownloader) rankEngines(ctx context.Context, platform, mediaType string, strat platforms.Strategy, engines []platforms.Engine) []platforms.Engine {
+	if p.Scores == nil {
+		return engines
+	}
+	ad, ok := strat.(platforms.AdaptiveStrategy)
+	if !ok || !ad.AdaptiveRanking() {
+		return engines
+	}
+	ranked := p.Scores.Rank(platform, mediaType, engines)
+	if names(ranked) != names(engines) {
+		p.logfCtx(ctx, "[rank] platform=%s type=%s order=%s (hand: %s)", platform, mediaType, names(ranked), names(engines))
+	}
+	return ranked
+}
+
+// observe feeds an engine run to the scoreboard. Runs cut short by the job's
+// own context are not the engine's fault and are not counted.
+func (p *PipelineDownloader) observe(ctx context.Context, platform, mediaType, engine string, err error, took time.Duration) {
+	if p.Scores == nil || (err != nil && ctx.Err() != nil) {
+		return
+	}
+	// A post with no media, behind a login wall or handed to another engine
+	// isn't the engine failing.
+	ok := err == nil || errors.Is(err, platforms.ErrNoMedia) || errors.Is(err, platforms.ErrLoginRequired) || platforms.HandoffTarget(err) != ""
+	p.Scores.Observe(platform, mediaType, engine, ok, took)
+}
+
+func names(engines []platforms.Engine) string {
+	out := make([]string, 0, len(engines))
+	for _, e := range engines {
+		out = append(out, e.Name())
+	}
+	return strings.Join(out, ",")
+}
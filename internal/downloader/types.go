@@ -6,6 +6,11 @@ import (
 	"telegram_bot_downloader/internal/model"
 )
 
+// Detector probes a URL's metadata before downloading.
+type Detector interface {
+	Detect(ctx context.Context, url string) (*MediaInfo, error)
+}
+
 type Downloader interface {
 	Detect(ctx context.Context, url string) (*MediaInfo, error)
 	Download(ctx context.Context, url string, jobDir string) (*DownloadResult, error)
@@ -14,4 +19,5 @@ type Downloader interface {
 // Aliases kept in downloader package to match requested API shape.
 type MediaInfo = model.MediaInfo
 type DownloadResult = model.DownloadResult
+type MediaFile = model.MediaFile
 
@@ -0,0 +1,60 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+
+	"telegram_bot_downloader/internal/media"
+	"telegram_bot_downloader/internal/platforms"
+)
+
+// validateResult checks every file an engine produced (see media.Validate).
+// For an engine that reports post positions, only the bad files are deleted
+// and their positions go to res.Missing (fillMissing may fetch them from
+// another engine); the attempt fails only when nothing valid is left. For any
+// other engine one bad file fails the whole attempt and all of its files are
+// deleted, so nothing half-valid leaks into the job dir for the next engine's
+// result.
+func (p *PipelineDownloader) validateResult(ctx context.Context, engine platforms.Engine, res *DownloadResult) error {
+	engineName := engine.Name()
+	var errs []error
+	bad := make([]bool, len(res.Files))
+	for i, f := range res.Files {
+		if _, err := media.Validate(ctx, f); err != nil {
+			errs = append(errs, err)
+			bad[i] = true
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	err := errors.Join(errs...)
+
+	pe, positional := engine.(platforms.PositionalEngine)
+	if positional && pe.SupportsPositions() && len(res.Items) == len(res.Files) && len(errs) < len(res.Files) {
+		var files []string
+		var items []MediaFile
+		for i, item := range res.Items {
+			if bad[i] {
+				_ = os.Remove(item.Path)
+				res.Missing = append(res.Missing, item.Position)
+				continue
+			}
+			files = append(files, item.Path)
+			items = append(items, item)
+		}
+		sort.Ints(res.Missing)
+		res.Files, res.Items = files, items
+		res.Size = fileTotalSize(files)
+		p.logfCtx(ctx, "[validate] engine=%s dropped files=%d missing=%v err=%v", engineName, len(errs), res.Missing, err)
+		return nil
+	}
+
+	for _, f := range res.Files {
+		_ = os.Remove(f)
+	}
+	p.logfCtx(ctx, "[validate] engine=%s rejected files=%d err=%v", engineName, len(res.Files), err)
+	return err
+}
@@ -8,8 +8,9 @@ import "sync"
 
 // Item is one cached Telegram media reference for a URL.
 type Item struct {
-	Kind   string // "video", "animation", "photo", or "document"
+	Kind   string // "video", "animation", "photo", "audio", or "document"
 	FileID string
+	Album  bool // sent inside a media group (slideshow slides); re-sent as one
 }
 
 // Cache is a bounded, concurrency-safe URL -> []Item store with FIFO eviction.
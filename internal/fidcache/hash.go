@@ -0,0 +1,74 @@
+package fidcache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// The same post reached through different URLs (vm.tiktok.com/…, /@u/video/…,
+// /t/…) misses the URL-keyed cache but downloads byte-identical files. A
+// content key lets those share one set of file_ids.
+
+const (
+	// Files up to this size are hashed in full.
+	fullHashMaxBytes = 8 << 20
+	// Bigger files are fingerprinted by their size plus this many evenly
+	// spaced chunks of sampleChunkBytes: enough to tell different videos
+	// apart without reading hundreds of MB.
+	sampleChunks     = 8
+	sampleChunkBytes = 64 << 10
+)
+
+// FileHash fingerprints one file's content: SHA-256 of the whole file when
+// small, else of its size and sampled chunks.
+func FileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	size := st.Size()
+	if size <= fullHashMaxBytes {
+		h.Write([]byte("full:"))
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	h.Write([]byte("sampled:"))
+	_ = binary.Write(h, binary.BigEndian, size)
+	buf := make([]byte, sampleChunkBytes)
+	step := (size - sampleChunkBytes) / (sampleChunks - 1)
+	for i := int64(0); i < sampleChunks; i++ {
+		n, err := f.ReadAt(buf, i*step)
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		h.Write(buf[:n])
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ContentKey fingerprints an ordered set of files (one download result), for
+// use as a Cache key next to the URL-keyed one.
+func ContentKey(files []string) (string, error) {
+	h := sha256.New()
+	for _, f := range files {
+		fh, err := FileHash(f)
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte(fh))
+	}
+	return "content:" + hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// RequireToken wraps h so it only answers requests carrying the token, either
+// as "Authorization: Bearer <token>" or "?token=<token>". An empty token
+// disables the endpoint entirely (404), so nothing leaks when it isn't set.
+func RequireToken(token string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			http.NotFound(w, r)
+			return
+		}
+		got := r.URL.Query().Get("token")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			got = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// WriteJSON writes v as indented JSON.
+func WriteJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+// Register mounts GET /jobs (newest first) and GET /jobs/{id} on mux, guarded
+// by RequireToken.
+func (m *Manager) Register(mux *http.ServeMux, token string) {
+	mux.HandleFunc("GET /jobs", RequireToken(token, func(w http.ResponseWriter, _ *http.Request) {
+		WriteJSON(w, m.List())
+	}))
+	mux.HandleFunc("GET /jobs/{id}", RequireToken(token, func(w http.ResponseWriter, r *http.Request) {
+		s, ok := m.Get(r.PathValue("id"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		WriteJSON(w, s)
+	}))
+}
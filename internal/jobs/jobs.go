@@ -0,0 +1,198 @@
+// Package jobs records the lifecycle of each download job (state, timestamps,
+// engines tried, errors) in a bounded in-memory history, so support questions
+// can be answered from /jobs instead of grepping logs.
+package jobs
+
+import (
+	"sync"
+	"time"
+)
+
+// State is a job's lifecycle stage.
+type State string
+
+const (
+	StateQueued      State = "queued"
+	StateDetecting   State = "detecting"
+	StateDownloading State = "downloading"
+	StateUploading   State = "uploading"
+	StateDone        State = "done"
+	StateFailed      State = "failed"
+)
+
+// Attempt is one engine run within a job.
+type Attempt struct {
+	Engine     string        `json:"engine"`
+	StartedAt  time.Time     `json:"started_at"`
+	Duration   time.Duration `json:"duration_ns"`
+	Error      string        `json:"error,omitempty"`
+	InProgress bool          `json:"in_progress,omitempty"`
+}
+
+// Snapshot is a point-in-time copy of a job, safe to serialize.
+type Snapshot struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	ChatID     int64     `json:"chat_id"`
+	Platform   string    `json:"platform,omitempty"`
+	Type       string    `json:"type,omitempty"`
+	Title      string    `json:"title,omitempty"`
+	Uploader   string    `json:"uploader,omitempty"`
+	Thumbnail  string    `json:"thumbnail,omitempty"`
+	State      State     `json:"state"`
+	Engine     string    `json:"engine,omitempty"` // engine currently (or last) downloading
+	Attempts   []Attempt `json:"attempts,omitempty"`
+	Files      int       `json:"files,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+}
+
+// Job is a live job record. All methods are safe for concurrent use; it also
+// satisfies downloader.JobEvents so the pipeline can report engine attempts.
+type Job struct {
+	mu sync.Mutex
+	s  Snapshot
+}
+
+func (j *Job) update(fn func(s *Snapshot)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(&j.s)
+	j.s.UpdatedAt = time.Now()
+}
+
+// Snapshot returns a copy of the job's current record.
+func (j *Job) Snapshot() Snapshot {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	s := j.s
+	s.Attempts = append([]Attempt(nil), j.s.Attempts...)
+	return s
+}
+
+// SetState moves the job to a new lifecycle stage.
+func (j *Job) SetState(st State) {
+	j.update(func(s *Snapshot) { s.State = st })
+}
+
+// SetInfo records the detected platform and media type.
+func (j *Job) SetInfo(platform, typ string) {
+	j.update(func(s *Snapshot) { s.Platform, s.Type = platform, typ })
+}
+
+// SetMeta records the post's title, uploader and thumbnail, once known.
+func (j *Job) SetMeta(title, uploader, thumbnail string) {
+	j.update(func(s *Snapshot) { s.Title, s.Uploader, s.Thumbnail = title, uploader, thumbnail })
+}
+
+// Detecting marks the job as probing the link's metadata.
+func (j *Job) Detecting() { j.SetState(StateDetecting) }
+
+// EngineStarted records a new engine attempt and moves the job to downloading.
+func (j *Job) EngineStarted(engine string) {
+	j.update(func(s *Snapshot) {
+		s.State = StateDownloading
+		s.Engine = engine
+		s.Attempts = append(s.Attempts, Attempt{Engine: engine, StartedAt: time.Now(), InProgress: true})
+	})
+}
+
+// EngineFinished closes the engine's open attempt with its outcome.
+func (j *Job) EngineFinished(engine string, err error) {
+	j.update(func(s *Snapshot) {
+		for i := len(s.Attempts) - 1; i >= 0; i-- {
+			a := &s.Attempts[i]
+			if a.Engine != engine || !a.InProgress {
+				continue
+			}
+			a.InProgress = false
+			a.Duration = time.Since(a.StartedAt)
+			if err != nil {
+				a.Error = err.Error()
+			}
+			return
+		}
+	})
+}
+
+// Done marks the job as delivered.
+func (j *Job) Done(files int) {
+	j.update(func(s *Snapshot) {
+		s.State = StateDone
+		s.Files = files
+		s.FinishedAt = time.Now()
+	})
+}
+
+// Fail marks the job as failed with err.
+func (j *Job) Fail(err error) {
+	j.update(func(s *Snapshot) {
+		s.State = StateFailed
+		if err != nil {
+			s.Error = err.Error()
+		}
+		s.FinishedAt = time.Now()
+	})
+}
+
+// Manager keeps the most recent jobs, evicting the oldest beyond capacity.
+type Manager struct {
+	mu    sync.Mutex
+	max   int
+	jobs  map[string]*Job
+	order []string // creation order, for eviction once over capacity
+}
+
+// NewManager returns a manager keeping at most max jobs (default 500).
+func NewManager(max int) *Manager {
+	if max <= 0 {
+		max = 500
+	}
+	return &Manager{max: max, jobs: make(map[string]*Job)}
+}
+
+// Start registers a new queued job.
+func (m *Manager) Start(id, url string, chatID int64) *Job {
+	now := time.Now()
+	j := &Job{s: Snapshot{ID: id, URL: url, ChatID: chatID, State: StateQueued, CreatedAt: now, UpdatedAt: now}}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.jobs[id]; !exists {
+		m.order = append(m.order, id)
+		for len(m.order) > m.max {
+			oldest := m.order[0]
+			m.order = m.order[1:]
+			delete(m.jobs, oldest)
+		}
+	}
+	m.jobs[id] = j
+	return j
+}
+
+// Get returns a job's snapshot by ID.
+func (m *Manager) Get(id string) (Snapshot, bool) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		return Snapshot{}, false
+	}
+	return j.Snapshot(), true
+}
+
+// List returns snapshots of all kept jobs, newest first.
+func (m *Manager) List() []Snapshot {
+	m.mu.Lock()
+	js := make([]*Job, 0, len(m.order))
+	for i := len(m.order) - 1; i >= 0; i-- {
+		js = append(js, m.jobs[m.order[i]])
+	}
+	m.mu.Unlock()
+	out := make([]Snapshot, 0, len(js))
+	for _, j := range js {
+		out = append(out, j.Snapshot())
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"telegram_bot_downloader/internal/execx"
+)
+
+// ToAnimationMP4 converts a GIF / animated WebP into a silent H.264 MP4 next to
+// the source (same name, ".anim.mp4"). Telegram plays such a file as a looping
+// animation and it is far smaller than the GIF. Dimensions are forced even
+// because yuv420p can't encode odd sizes.
+func ToAnimationMP4(ctx context.Context, src string) (string, error) {
+	dst := strings.TrimSuffix(src, filepath.Ext(src)) + ".anim.mp4"
+	res, err := execx.Run(ctx, "ffmpeg",
+		"-y", "-v", "error",
+		"-i", src,
+		"-an",
+		"-movflags", "+faststart",
+		"-pix_fmt", "yuv420p",
+		"-vf", "scale=trunc(iw/2)*2:trunc(ih/2)*2",
+		"-c:v", "libx264",
+		dst,
+	)
+	if err != nil {
+		_ = os.Remove(dst)
+		if out := strings.TrimSpace(res.Output); out != "" {
+			return "", fmt.Errorf("ffmpeg: %w: %s", err, out)
+		}
+		return "", fmt.Errorf("ffmpeg: %w", err)
+	}
+	return dst, nil
+}
+
+// RenderSlideshow renders still images into an MP4 (1080x1920, letterboxed)
+// where slide i stays on screen for durations[i] seconds, with audio (optional,
+// "" for none) as the soundtrack. It uses ffmpeg's concat demuxer; the list file
+// is written next to dst and removed afterwards.
+func RenderSlideshow(ctx context.Context, images []string, durations []float64, audio, dst string) error {
+	if len(images) == 0 || len(images) != len(durations) {
+		return fmt.Errorf("slideshow: %d images, %d durations", len(images), len(durations))
+	}
+	var list strings.Builder
+	for i, img := range images {
+		abs, err := filepath.Abs(img)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&list, "file '%s'\nduration %.3f\n", strings.ReplaceAll(abs, "'", `'\''`), durations[i])
+	}
+	// The concat demuxer ignores the last entry's duration unless the file is
+	// listed once more.
+	last, _ := filepath.Abs(images[len(images)-1])
+	fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(last, "'", `'\''`))
+
+	listPath := dst + ".txt"
+	if err := os.WriteFile(listPath, []byte(list.String()), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(listPath)
+
+	args := []string{"-y", "-v", "error", "-f", "concat", "-safe", "0", "-i", listPath}
+	if audio != "" {
+		args = append(args, "-i", audio)
+	}
+	args = append(args,
+		"-vf", "scale=1080:1920:force_original_aspect_ratio=decrease,pad=1080:1920:(ow-iw)/2:(oh-ih)/2,format=yuv420p",
+		"-r", "30",
+		"-c:v", "libx264",
+		"-movflags", "+faststart",
+	)
+	if audio != "" {
+		args = append(args, "-c:a", "aac", "-shortest")
+	}
+	args = append(args, dst)
+
+	res, err := execx.Run(ctx, "ffmpeg", args...)
+	if err != nil {
+		_ = os.Remove(dst)
+		if out := strings.TrimSpace(res.Output); out != "" {
+			return fmt.Errorf("ffmpeg: %w: %s", err, out)
+		}
+		return fmt.Errorf("ffmpeg: %w", err)
+	}
+	return nil
+}
+
+// ToJPEG converts a still image Telegram's sendPhoto handles badly (HEIC,
+// AVIF, WebP) into a high-quality JPEG next to the source (same name, ".jpg").
+// ffmpeg is tried first; HEIC/AVIF builds it can't decode fall back to
+// libheif's heif-convert.
+func ToJPEG(ctx context.Context, src string) (string, error) {
+	dst := strings.TrimSuffix(src, filepath.Ext(src)) + ".jpg"
+	if dst == src {
+		dst = strings.TrimSuffix(src, filepath.Ext(src)) + ".conv.jpg"
+	}
+	res, err := execx.Run(ctx, "ffmpeg",
+		"-y", "-v", "error",
+		"-i", src,
+		"-frames:v", "1",
+		"-q:v", "2",
+		"-pix_fmt", "yuvj444p",
+		dst,
+	)
+	if err == nil {
+		return dst, nil
+	}
+	_ = os.Remove(dst)
+	ffErr := strings.TrimSpace(res.Output)
+
+	hres, herr := execx.Run(ctx, "heif-convert", "-q", "92", src, dst)
+	if herr == nil {
+		return dst, nil
+	}
+	_ = os.Remove(dst)
+	return "", fmt.Errorf("ffmpeg: %v: %s; heif-convert: %v: %s", err, ffErr, herr, strings.TrimSpace(hres.Output))
+}
+
+// MergeAV muxes a video-only and an audio-only track (e.g. the separate DASH
+// renditions Reddit serves) into dst without re-encoding.
+func MergeAV(ctx context.Context, video, audio, dst string) error {
+	res, err := execx.Run(ctx, "ffmpeg",
+		"-y", "-v", "error",
+		"-i", video,
+		"-i", audio,
+		"-map", "0:v:0",
+		"-map", "1:a:0",
+		"-c", "copy",
+		"-movflags", "+faststart",
+		dst,
+	)
+	if err != nil {
+		_ = os.Remove(dst)
+		if out := strings.TrimSpace(res.Output); out != "" {
+			return fmt.Errorf("ffmpeg: %w: %s", err, out)
+		}
+		return fmt.Errorf("ffmpeg: %w", err)
+	}
+	return nil
+}
+
+// FetchHLS downloads an HLS stream (its highest-bandwidth variant, ffmpeg's
+// default pick) into an MP4 at dst without re-encoding.
+func FetchHLS(ctx context.Context, src, dst string) error {
+	res, err := execx.Run(ctx, "ffmpeg",
+		"-y", "-v", "error",
+		"-i", src,
+		"-c", "copy",
+		"-bsf:a", "aac_adtstoasc",
+		"-movflags", "+faststart",
+		dst,
+	)
+	if err != nil {
+		_ = os.Remove(dst)
+		if out := strings.TrimSpace(res.Output); out != "" {
+			return fmt.Errorf("ffmpeg: %w: %s", err, out)
+		}
+		return fmt.Errorf("ffmpeg: %w", err)
+	}
+	return nil
+}
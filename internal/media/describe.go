@@ -0,0 +1,49 @@
+package media
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"telegram_bot_downloader/internal/model"
+)
+
+// Describe fills in what the file at mf.Path says about itself. Kind, MIME
+// (sniffed, with Kind falling back to the extension) and size are always
+// refreshed, since post-processing may rewrite the file; image dimensions are
+// read from the header (no full decode) when missing. Video dimensions and
+// durations come from the engine, or ProbeFile when a caller needs them.
+func Describe(mf *model.MediaFile) {
+	if st, err := os.Stat(mf.Path); err == nil {
+		mf.Size = st.Size()
+	}
+	ext := strings.ToLower(filepath.Ext(mf.Path))
+	if f, ok := Sniff(mf.Path); ok {
+		mf.Kind, mf.MIME = f.Kind, f.MIME
+		if f.Ext == ".mp4" && ext == ".m4a" {
+			mf.Kind = "audio" // audio-only MP4 with a generic ftyp brand
+		}
+	} else {
+		// A format the sniffer doesn't know: go by the extension.
+		switch ext {
+		case ".mp4", ".mov", ".webm", ".mkv", ".avi", ".m4v":
+			mf.Kind = "video"
+		case ".mp3", ".m4a", ".aac", ".ogg", ".opus":
+			mf.Kind = "audio"
+		default:
+			mf.Kind = "image"
+		}
+	}
+	if mf.Kind != "image" || (mf.Width > 0 && mf.Height > 0) {
+		return
+	}
+	r, err := os.Open(mf.Path)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	if cfg, _, err := image.DecodeConfig(r); err == nil {
+		mf.Width, mf.Height = cfg.Width, cfg.Height
+	}
+}
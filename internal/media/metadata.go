@@ -0,0 +1,202 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"telegram_bot_downloader/internal/execx"
+)
+
+// All rewrites below go through a temp file + rename, never in place: the
+// downloaded file may be hardlinked into the disk cache.
+
+// JPEG markers dropped by StripJPEGMetadata: EXIF/XMP (APP1), IPTC (APP13) and
+// comments. APP0 (JFIF), APP2 (ICC colour profile) and APP14 (Adobe, needed to
+// decode CMYK) are kept.
+var jpegStripMarkers = map[byte]bool{0xE1: true, 0xED: true, 0xFE: true}
+
+// StripJPEGMetadata removes EXIF/XMP/IPTC/comment segments from a JPEG without
+// re-encoding it. Images whose EXIF orientation rotates them are left alone:
+// dropping the tag would show them sideways. Reports whether the file changed.
+func StripJPEGMetadata(path string) (bool, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if len(raw) < 4 || raw[0] != 0xFF || raw[1] != 0xD8 {
+		return false, fmt.Errorf("%s: not a JPEG", filepath.Base(path))
+	}
+
+	var out bytes.Buffer
+	out.Write(raw[:2])
+	i := 2
+	stripped := false
+	for i+4 <= len(raw) {
+		if raw[i] != 0xFF {
+			return false, fmt.Errorf("%s: bad JPEG marker at %d", filepath.Base(path), i)
+		}
+		marker := raw[i+1]
+		if marker == 0xFF { // fill byte
+			i++
+			continue
+		}
+		if marker == 0xDA { // start of scan: the rest is image data
+			break
+		}
+		n := int(binary.BigEndian.Uint16(raw[i+2 : i+4]))
+		end := i + 2 + n
+		if n < 2 || end > len(raw) {
+			return false, fmt.Errorf("%s: truncated JPEG segment", filepath.Base(path))
+		}
+		if marker == 0xE1 && exifOrientation(raw[i+4:end]) > 1 {
+			return false, nil
+		}
+		if jpegStripMarkers[marker] {
+			stripped = true
+		} else {
+			out.Write(raw[i:end])
+		}
+		i = end
+	}
+	if !stripped {
+		return false, nil
+	}
+	out.Write(raw[i:])
+	return true, replaceFile(path, out.Bytes())
+}
+
+// exifOrientation returns the Orientation tag (1..8) from an APP1 payload, or
+// 0 when it isn't EXIF or has no orientation.
+func exifOrientation(app1 []byte) int {
+	if !bytes.HasPrefix(app1, []byte("Exif\x00\x00")) {
+		return 0
+	}
+	tiff := app1[6:]
+	if len(tiff) < 8 {
+		return 0
+	}
+	var bo binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := int(bo.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(bo.Uint16(tiff[ifd : ifd+2]))
+	for k := 0; k < count; k++ {
+		e := ifd + 2 + 12*k
+		if e+12 > len(tiff) {
+			return 0
+		}
+		if bo.Uint16(tiff[e:e+2]) == 0x0112 { // Orientation, SHORT
+			return int(bo.Uint16(tiff[e+8 : e+10]))
+		}
+	}
+	return 0
+}
+
+func replaceFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// StripVideoMetadata drops container/stream metadata (titles, encoder tags,
+// location) with a stream copy. MP4/MOV files are written moov-first in the
+// same pass, so Faststart has nothing left to do for them.
+func StripVideoMetadata(ctx context.Context, path string) error {
+	args := []string{"-map_metadata", "-1", "-map_chapters", "-1"}
+	if isMP4Family(path) {
+		args = append(args, "-movflags", "+faststart")
+	}
+	return remux(ctx, path, args...)
+}
+
+// Faststart moves an MP4/MOV's moov atom to the front so Telegram can start
+// playback before the whole file is fetched. Other containers are skipped.
+func Faststart(ctx context.Context, path string) error {
+	if !isMP4Family(path) || moovFirst(path) {
+		return nil
+	}
+	return remux(ctx, path, "-movflags", "+faststart")
+}
+
+func isMP4Family(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp4", ".m4v", ".mov":
+		return true
+	}
+	return false
+}
+
+// moovFirst reports whether the moov atom already precedes mdat.
+func moovFirst(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	hdr := make([]byte, 8)
+	for k := 0; k < 16; k++ {
+		if _, err := io.ReadFull(r, hdr); err != nil {
+			return false
+		}
+		size := int64(binary.BigEndian.Uint32(hdr[:4]))
+		switch string(hdr[4:8]) {
+		case "moov":
+			return true
+		case "mdat":
+			return false
+		}
+		skip := size - 8
+		if size == 1 { // 64-bit size follows
+			ext := make([]byte, 8)
+			if _, err := io.ReadFull(r, ext); err != nil {
+				return false
+			}
+			skip = int64(binary.BigEndian.Uint64(ext)) - 16
+		}
+		if skip < 0 {
+			return false
+		}
+		if _, err := r.Discard(int(skip)); err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+// remux stream-copies path through ffmpeg with extra output args and replaces
+// the original on success.
+func remux(ctx context.Context, path string, args ...string) error {
+	ext := filepath.Ext(path)
+	tmp := strings.TrimSuffix(path, ext) + ".remux" + ext
+	full := append([]string{"-y", "-v", "error", "-i", path, "-map", "0", "-c", "copy"}, args...)
+	full = append(full, tmp)
+	res, err := execx.Run(ctx, "ffmpeg", full...)
+	if err != nil {
+		_ = os.Remove(tmp)
+		if out := strings.TrimSpace(res.Output); out != "" {
+			return fmt.Errorf("ffmpeg: %w: %s", err, out)
+		}
+		return fmt.Errorf("ffmpeg: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,151 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Telegram's sendPhoto limits. Past them the API rejects the photo outright.
+const (
+	PhotoMaxBytes  = 10 << 20
+	PhotoMaxDimSum = 10000 // width + height
+	PhotoMaxAspect = 20    // long side / short side
+)
+
+// photoMaxPixels caps what FitPhoto will decode (~400 MB as RGBA); bigger
+// images go as documents instead of risking the process's memory.
+const photoMaxPixels = 100_000_000
+
+// FitPhoto makes an image acceptable to sendPhoto. It returns the file to
+// send (path itself, or a downscaled/recompressed JPEG next to it) and
+// asPhoto=false when no photo can satisfy the limits (extreme aspect ratio,
+// huge or undecodable image) and the original should go as a document to keep
+// its full resolution.
+func FitPhoto(path string) (out string, asPhoto bool, err error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return "", false, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	cfg, _, cerr := image.DecodeConfig(f)
+	f.Close()
+	if cerr != nil {
+		// Not a format we can measure: let Telegram judge unless it's too big.
+		return path, st.Size() <= PhotoMaxBytes, nil
+	}
+	w, h := cfg.Width, cfg.Height
+	if w <= 0 || h <= 0 {
+		return path, false, nil
+	}
+	if float64(max(w, h))/float64(min(w, h)) > PhotoMaxAspect {
+		return path, false, nil
+	}
+	if st.Size() <= PhotoMaxBytes && w+h <= PhotoMaxDimSum {
+		return path, true, nil
+	}
+	if w*h > photoMaxPixels {
+		return path, false, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return path, false, err
+	}
+
+	// Shrink to the dimension limit (with a little margin), then trade
+	// quality and size until the encoding fits the byte limit.
+	scale := min(1, float64(PhotoMaxDimSum-10)/float64(w+h))
+	for attempt := 0; attempt < 4; attempt++ {
+		dw, dh := max(1, int(float64(w)*scale)), max(1, int(float64(h)*scale))
+		small := img
+		if dw != w || dh != h {
+			small = downscale(img, dw, dh)
+		}
+		// JPEG has no alpha: transparent areas of a PNG/WebP would come out
+		// black, so put them on white the way image viewers show them.
+		small = flattenOnWhite(small)
+		for _, q := range []int{90, 82, 75} {
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, small, &jpeg.Options{Quality: q}); err != nil {
+				return path, false, err
+			}
+			if buf.Len() <= PhotoMaxBytes {
+				out = strings.TrimSuffix(path, filepath.Ext(path)) + ".fit.jpg"
+				if err := os.WriteFile(out, buf.Bytes(), 0644); err != nil {
+					return path, false, err
+				}
+				return out, true, nil
+			}
+		}
+		scale *= 0.75
+	}
+	return path, false, fmt.Errorf("%s: still over %d bytes after downscaling", filepath.Base(path), PhotoMaxBytes)
+}
+
+// flattenOnWhite composites an image with transparency onto a white
+// background; opaque images are returned as they are.
+func flattenOnWhite(src image.Image) image.Image {
+	if o, ok := src.(interface{ Opaque() bool }); ok && o.Opaque() {
+		return src
+	}
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Over)
+	return dst
+}
+
+// downscale resizes src to dw x dh by averaging the source pixels that fall
+// into each destination pixel (a box filter: fine for shrinking photos).
+func downscale(src image.Image, dw, dh int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	type acc struct{ r, g, b, a, n uint64 }
+	row := make([]acc, dw)
+	dy := 0
+	flush := func() {
+		for x := range row {
+			s := &row[x]
+			if s.n > 0 {
+				dst.SetRGBA(x, dy, color.RGBA{
+					R: uint8(s.r / s.n >> 8), G: uint8(s.g / s.n >> 8),
+					B: uint8(s.b / s.n >> 8), A: uint8(s.a / s.n >> 8),
+				})
+			}
+			row[x] = acc{}
+		}
+	}
+	for sy := 0; sy < sh; sy++ {
+		if ty := sy * dh / sh; ty != dy {
+			flush()
+			dy = ty
+		}
+		for sx := 0; sx < sw; sx++ {
+			r, g, bl, a := src.At(b.Min.X+sx, b.Min.Y+sy).RGBA()
+			s := &row[sx*dw/sw]
+			s.r += uint64(r)
+			s.g += uint64(g)
+			s.b += uint64(bl)
+			s.a += uint64(a)
+			s.n++
+		}
+	}
+	flush()
+	return dst
+}
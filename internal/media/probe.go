@@ -0,0 +1,66 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"telegram_bot_downloader/internal/execx"
+)
+
+// Probe is the subset of ffprobe output the bot cares about.
+type Probe struct {
+	Duration float64 // seconds; 0 when the container doesn't report one
+	HasVideo bool
+	HasAudio bool
+	Width    int
+	Height   int
+}
+
+type ffprobeOut struct {
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// ProbeFile runs a single ffprobe over the file and reports its streams and
+// duration. One spawn (~50ms) answers every question the sender asks.
+func ProbeFile(ctx context.Context, path string) (Probe, error) {
+	res, err := execx.Run(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "stream=codec_type,width,height:format=duration",
+		"-of", "json",
+		path,
+	)
+	if err != nil {
+		if out := strings.TrimSpace(res.Output); out != "" {
+			return Probe{}, fmt.Errorf("ffprobe: %w: %s", err, out)
+		}
+		return Probe{}, fmt.Errorf("ffprobe: %w", err)
+	}
+	var raw ffprobeOut
+	if err := json.Unmarshal([]byte(res.Output), &raw); err != nil {
+		return Probe{}, fmt.Errorf("ffprobe: decode: %w", err)
+	}
+	var p Probe
+	for _, s := range raw.Streams {
+		switch s.CodecType {
+		case "video":
+			if !p.HasVideo {
+				p.Width, p.Height = s.Width, s.Height
+			}
+			p.HasVideo = true
+		case "audio":
+			p.HasAudio = true
+		}
+	}
+	p.Duration, _ = strconv.ParseFloat(raw.Format.Duration, 64)
+	return p, nil
+}
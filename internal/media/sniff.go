@@ -0,0 +1,130 @@
+// Package media inspects downloaded files — container sniffing from magic bytes
+// and ffprobe/ffmpeg helpers — so the sender can pick the right Telegram method
+// without trusting file extensions.
+package media
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+func readHead(path string, n int) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	buf := make([]byte, n)
+	m, _ := io.ReadFull(f, buf)
+	return buf[:m]
+}
+
+// IsGIF reports whether the file is a GIF (GIF87a/GIF89a header).
+func IsGIF(path string) bool {
+	h := readHead(path, 6)
+	return bytes.Equal(h, []byte("GIF87a")) || bytes.Equal(h, []byte("GIF89a"))
+}
+
+// IsAnimatedWebP reports whether the file is a WebP whose extended header (VP8X)
+// has the animation flag set. Still WebPs (VP8 / VP8L) report false.
+func IsAnimatedWebP(path string) bool {
+	h := readHead(path, 21)
+	if len(h) < 21 || !bytes.Equal(h[0:4], []byte("RIFF")) || !bytes.Equal(h[8:12], []byte("WEBP")) {
+		return false
+	}
+	if !bytes.Equal(h[12:16], []byte("VP8X")) {
+		return false
+	}
+	// VP8X chunk: 4-byte size (h[16:20]), then a flags byte; 0x02 = animation.
+	return h[20]&0x02 != 0
+}
+
+// Format is a file's container as identified from its magic bytes.
+type Format struct {
+	Kind string // "image", "video" or "audio"
+	Ext  string // canonical extension, e.g. ".jpg"
+	MIME string
+}
+
+var (
+	fmtJPEG = Format{"image", ".jpg", "image/jpeg"}
+	fmtPNG  = Format{"image", ".png", "image/png"}
+	fmtGIF  = Format{"image", ".gif", "image/gif"}
+	fmtWebP = Format{"image", ".webp", "image/webp"}
+	fmtHEIC = Format{"image", ".heic", "image/heic"}
+	fmtAVIF = Format{"image", ".avif", "image/avif"}
+	fmtMP4  = Format{"video", ".mp4", "video/mp4"}
+	fmtMOV  = Format{"video", ".mov", "video/quicktime"}
+	fmtWebM = Format{"video", ".webm", "video/webm"}
+	fmtMKV  = Format{"video", ".mkv", "video/x-matroska"}
+	fmtM4A  = Format{"audio", ".m4a", "audio/mp4"}
+	fmtMP3  = Format{"audio", ".mp3", "audio/mpeg"}
+	fmtAAC  = Format{"audio", ".aac", "audio/aac"}
+	fmtOGG  = Format{"audio", ".ogg", "audio/ogg"}
+)
+
+// sniffLen covers every signature below (EBML doctype included).
+const sniffLen = 64
+
+// Sniff identifies the file's media format from its content. ok is false for
+// anything that isn't a known image/video/audio container (HTML error pages,
+// JSON, truncated or empty files).
+func Sniff(path string) (f Format, ok bool) {
+	return sniffBytes(readHead(path, sniffLen))
+}
+
+func sniffBytes(h []byte) (Format, bool) {
+	switch {
+	case len(h) < 4:
+		return Format{}, false
+	case h[0] == 0xFF && h[1] == 0xD8 && h[2] == 0xFF:
+		return fmtJPEG, true
+	case bytes.HasPrefix(h, []byte("\x89PNG\r\n\x1a\n")):
+		return fmtPNG, true
+	case bytes.HasPrefix(h, []byte("GIF87a")) || bytes.HasPrefix(h, []byte("GIF89a")):
+		return fmtGIF, true
+	case len(h) >= 12 && bytes.Equal(h[0:4], []byte("RIFF")) && bytes.Equal(h[8:12], []byte("WEBP")):
+		return fmtWebP, true
+	case len(h) >= 12 && bytes.Equal(h[4:8], []byte("ftyp")):
+		return sniffFtyp(h), true
+	case len(h) >= 8 && (bytes.Equal(h[4:8], []byte("moov")) || bytes.Equal(h[4:8], []byte("mdat")) || bytes.Equal(h[4:8], []byte("free")) || bytes.Equal(h[4:8], []byte("wide"))):
+		return fmtMOV, true
+	case bytes.HasPrefix(h, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		if bytes.Contains(h, []byte("webm")) {
+			return fmtWebM, true
+		}
+		return fmtMKV, true
+	case bytes.HasPrefix(h, []byte("OggS")):
+		return fmtOGG, true
+	case bytes.HasPrefix(h, []byte("ID3")):
+		return fmtMP3, true
+	case h[0] == 0xFF && h[1]&0xF6 == 0xF0: // ADTS (layer bits 00)
+		return fmtAAC, true
+	case h[0] == 0xFF && h[1]&0xE0 == 0xE0 && h[1]&0x06 != 0: // MPEG audio frame sync
+		return fmtMP3, true
+	}
+	return Format{}, false
+}
+
+// sniffFtyp maps an ISO-BMFF ftyp box (major brand, then compatible brands)
+// to its format.
+func sniffFtyp(h []byte) Format {
+	switch string(h[8:12]) {
+	case "mif1", "msf1":
+		// Generic HEIF: AVIF lists "avif" among the compatible brands.
+		if bytes.Contains(h[12:], []byte("avif")) {
+			return fmtAVIF
+		}
+		return fmtHEIC
+	case "heic", "heix", "hevc", "hevx", "heim", "heis":
+		return fmtHEIC
+	case "avif", "avis":
+		return fmtAVIF
+	case "qt  ":
+		return fmtMOV
+	case "M4A ", "M4B ":
+		return fmtM4A
+	}
+	return fmtMP4
+}
@@ -0,0 +1,105 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"telegram_bot_downloader/internal/execx"
+)
+
+// ErrInvalidMedia marks a downloaded file that isn't the media it claims to be
+// (login-wall HTML saved as .jpg, truncated body, broken container).
+var ErrInvalidMedia = errors.New("invalid media file")
+
+// Smallest plausible file per kind: anything below is an error body or a
+// truncated download, not media.
+var minBytes = map[string]int64{"image": 100, "video": 4 << 10, "audio": 1 << 10}
+
+// Validate checks that path really is a complete image/video/audio file:
+// known magic bytes, a plausible size, a full decode for images and an
+// ffprobe container check for video and audio. Checks that need ffmpeg /
+// ffprobe are skipped when those aren't installed.
+func Validate(ctx context.Context, path string) (Format, error) {
+	name := filepath.Base(path)
+	st, err := os.Stat(path)
+	if err != nil {
+		return Format{}, err
+	}
+	f, ok := Sniff(path)
+	if !ok {
+		if looksLikeMarkup(readHead(path, 512)) {
+			return Format{}, fmt.Errorf("%w: %s is an HTML/JSON page", ErrInvalidMedia, name)
+		}
+		return Format{}, fmt.Errorf("%w: %s has no known media signature", ErrInvalidMedia, name)
+	}
+	if st.Size() < minBytes[f.Kind] {
+		return f, fmt.Errorf("%w: %s is only %d bytes", ErrInvalidMedia, name, st.Size())
+	}
+
+	switch f.Kind {
+	case "image":
+		err = validateImage(ctx, path, f)
+	default:
+		err = validateAV(ctx, path, f)
+	}
+	if err != nil {
+		return f, fmt.Errorf("%w: %s: %v", ErrInvalidMedia, name, err)
+	}
+	return f, nil
+}
+
+func validateImage(ctx context.Context, path string, f Format) error {
+	switch f {
+	case fmtJPEG, fmtPNG, fmtGIF:
+		r, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		_, _, err = image.Decode(r)
+		return err
+	}
+	// No stdlib decoder (WebP, HEIC, AVIF): let ffmpeg decode one frame.
+	res, err := execx.Run(ctx, "ffmpeg", "-v", "error", "-i", path, "-frames:v", "1", "-f", "null", "-")
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("decode: %s", strings.TrimSpace(res.Output))
+	}
+	return nil
+}
+
+func validateAV(ctx context.Context, path string, f Format) error {
+	p, err := ProbeFile(ctx, path)
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case f.Kind == "video" && !p.HasVideo && !p.HasAudio:
+		return fmt.Errorf("no streams")
+	case f.Kind == "audio" && !p.HasAudio:
+		return fmt.Errorf("no audio stream")
+	case p.Duration <= 0:
+		return fmt.Errorf("no duration")
+	}
+	return nil
+}
+
+func looksLikeMarkup(h []byte) bool {
+	h = bytes.ToLower(bytes.TrimSpace(bytes.TrimPrefix(h, []byte("\xef\xbb\xbf"))))
+	return bytes.HasPrefix(h, []byte("<")) || bytes.HasPrefix(h, []byte("{")) || bytes.HasPrefix(h, []byte("["))
+}
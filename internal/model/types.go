@@ -1,8 +1,10 @@
 package model
 
+import "time"
+
 type MediaInfo struct {
 	Platform   string
-	Type       string // video, image, carousel, unknown
+	Type       string // video, image, carousel, slideshow, unknown
 	Duration   int
 	Size       int64
 	Title      string
@@ -11,8 +13,38 @@ type MediaInfo struct {
 	Thumbnail  string
 }
 
+// MediaFile describes one downloaded file.
+type MediaFile struct {
+	Path      string
+	Kind      string // image, video, audio (sniffed from content)
+	MIME      string
+	Width     int
+	Height    int
+	Duration  float64 // seconds; 0 for stills or when unknown
+	Size      int64
+	Position  int    // 1-based position in the post / carousel
+	SourceURL string // CDN URL the file was fetched from, when known
+	Animation bool   // a silent looping clip the site shows as a GIF (sent with sendAnimation)
+}
+
+// Attempt is one engine run made for a download.
+type Attempt struct {
+	Engine    string
+	StartedAt time.Time
+	Duration  time.Duration
+	Err       string // empty on success
+}
+
 type DownloadResult struct {
 	Files []string
 	Size  int64
-}
 
+	Items    []MediaFile // per-file metadata, same order as Files
+	Engine   string      // engine that produced the files ("cache" on a disk cache hit)
+	Attempts []Attempt   // every engine run, in start order
+	Elapsed  time.Duration
+
+	// Missing lists the 1-based post positions an engine couldn't fetch: the
+	// result is a partial carousel, Files holds the rest (see MediaFile.Position).
+	Missing []int
+}
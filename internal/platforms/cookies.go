@@ -1,6 +1,8 @@
 package platforms
 
 import (
+	"bufio"
+	"net/http"
 	"os"
 	"strings"
 
@@ -17,6 +19,8 @@ func cookiesPathForPlatform(platform string) string {
 		return "facebook.txt"
 	case "pinterest":
 		return "pinterest.txt"
+	case "reddit":
+		return "reddit.txt"
 	default:
 		return ""
 	}
@@ -25,7 +29,13 @@ func cookiesPathForPlatform(platform string) string {
 // CookiesPathForURL returns a cookie file path when a non-empty Netscape-format
 // file exists for the URL's platform (see yt-dlp --cookies). Otherwise "".
 func CookiesPathForURL(rawURL string) string {
-	p := cookiesPathForPlatform(urlx.PlatformFromURL(strings.ToLower(rawURL)))
+	return CookiesPathForPlatform(urlx.PlatformFromURL(strings.ToLower(rawURL)))
+}
+
+// CookiesPathForPlatform is CookiesPathForURL for a platform name ("instagram",
+// "twitter", …).
+func CookiesPathForPlatform(platform string) string {
+	p := cookiesPathForPlatform(platform)
 	if p == "" {
 		return ""
 	}
@@ -35,3 +45,63 @@ func CookiesPathForURL(rawURL string) string {
 	}
 	return p
 }
+
+// loadCookies reads a platform's Netscape cookie file (see
+// CookiesPathForPlatform) for engines that make their own HTTP requests. Nil
+// when no file is loaded.
+func loadCookies(platform string) []*http.Cookie {
+	return readCookieFile(CookiesPathForPlatform(platform))
+}
+
+// readCookieFile parses a Netscape cookie file; nil for "" or a bad file.
+func readCookieFile(path string) []*http.Cookie {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var out []*http.Cookie
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		// "#HttpOnly_" marks an HttpOnly cookie, not a comment.
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// domain, include-subdomains, path, secure, expiry, name, value
+		f := strings.Split(line, "\t")
+		if len(f) < 7 {
+			continue
+		}
+		out = append(out, &http.Cookie{Name: f[5], Value: f[6], Domain: f[0], Path: f[2]})
+	}
+	return out
+}
+
+// cookieHeader renders cookies whose domain matches host as a Cookie header
+// value.
+func cookieHeader(cookies []*http.Cookie, host string) string {
+	var parts []string
+	for _, c := range cookies {
+		d := strings.TrimPrefix(c.Domain, ".")
+		if host == d || strings.HasSuffix(host, "."+d) {
+			parts = append(parts, c.Name+"="+c.Value)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// hasCookie reports whether cookies include a non-empty cookie called name
+// (e.g. Instagram's "sessionid", present only for a logged-in session).
+func hasCookie(cookies []*http.Cookie, name string) bool {
+	for _, c := range cookies {
+		if c.Name == name && c.Value != "" {
+			return true
+		}
+	}
+	return false
+}
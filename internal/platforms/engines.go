@@ -8,10 +8,12 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"telegram_bot_downloader/internal/execx"
+	"telegram_bot_downloader/internal/media"
 	"telegram_bot_downloader/internal/model"
 )
 
@@ -42,6 +44,8 @@ const browserUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36
 
 func (e YtDlpEngine) Name() string { return "yt-dlp" }
 
+func (YtDlpEngine) SupportsPositions() bool { return true }
+
 func (e YtDlpEngine) Download(ctx context.Context, url string, jobDir string, opts Options) (*model.DownloadResult, error) {
 	cmd := e.Cmd
 	if cmd == "" {
@@ -70,6 +74,10 @@ func (e YtDlpEngine) Download(ctx context.Context, url string, jobDir string, op
 		// multi-image tweets, Facebook albums) download every item, not just one.
 		"--yes-playlist",
 	}
+	if len(opts.Positions) > 0 {
+		// Only the items a previous engine's partial carousel is missing.
+		args = append(args, "--playlist-items", joinInts(opts.Positions, ","))
+	}
 
 	// Use a per-platform Netscape cookie file if one was provided via env
 	// (see ensureCookiesFileFromEnv); without a file we run anonymously.
@@ -139,10 +147,10 @@ func (e YtDlpEngine) Download(ctx context.Context, url string, jobDir string, op
 	if isMedia {
 		mediaArgs := append([]string{}, args...)
 		mediaArgs = append(mediaArgs, impersonateArgs...) // image posts are rarer; give them the anti-block muscle
-		mediaArgs = append(mediaArgs, "-f", "best", "--print", "after_move:filepath", "-o", out, "--", url)
-		files, err := runYtDlpAndCollectFiles(ctx, cmd, mediaArgs, jobDir)
+		mediaArgs = append(mediaArgs, "-f", "best", "--print", ytPrintTemplate, "-o", out, "--", url)
+		files, known, err := runYtDlpAndCollectFiles(ctx, cmd, mediaArgs, jobDir)
 		if err == nil && len(files) > 0 {
-			return &model.DownloadResult{Files: files, Size: totalSize(files)}, nil
+			return newResult(files, known), nil
 		}
 		// An image/carousel post gains nothing from the video-only cascade below
 		// (it would just waste time), so stop here and let the next engine try.
@@ -172,14 +180,14 @@ func (e YtDlpEngine) Download(ctx context.Context, url string, jobDir string, op
 	}
 	fastArgs = append(fastArgs, "-f", fastFormat)
 	fastArgs = append(fastArgs,
-		"--print", "after_move:filepath",
+		"--print", ytPrintTemplate,
 		"-o", out,
 		"--", url,
 	)
 
-	files, err := runYtDlpAndCollectFiles(ctx, cmd, fastArgs, jobDir)
+	files, known, err := runYtDlpAndCollectFiles(ctx, cmd, fastArgs, jobDir)
 	if err == nil && len(files) > 0 {
-		return &model.DownloadResult{Files: files, Size: totalSize(files)}, nil
+		return newResult(files, known), nil
 	}
 	// Reliability-first: never give up after the fast pass. A datacenter IP often
 	// returns an ambiguous "not available / sign in / rate-limited" that a
@@ -206,13 +214,13 @@ func (e YtDlpEngine) Download(ctx context.Context, url string, jobDir string, op
 		// Stream-copy remux only (fast). Codec normalization is left to the
 		// compat fallback below so the common case never pays a re-encode.
 		"--postprocessor-args", "ffmpeg:-movflags +faststart",
-		"--print", "after_move:filepath",
+		"--print", ytPrintTemplate,
 		"-o", out,
 		"--", url,
 	)
-	files, err = runYtDlpAndCollectFiles(ctx, cmd, qualityArgs, jobDir)
+	files, known, err = runYtDlpAndCollectFiles(ctx, cmd, qualityArgs, jobDir)
 	if err == nil && len(files) > 0 {
-		return &model.DownloadResult{Files: files, Size: totalSize(files)}, nil
+		return newResult(files, known), nil
 	}
 
 	// Fallback to compatibility selection (may require merge).
@@ -227,13 +235,13 @@ func (e YtDlpEngine) Download(ctx context.Context, url string, jobDir string, op
 			"-f", compatFormat,
 			"--merge-output-format", "mp4",
 			"--postprocessor-args", "ffmpeg:-movflags +faststart -pix_fmt yuv420p",
-			"--print", "after_move:filepath",
+			"--print", ytPrintTemplate,
 			"-o", out,
 			"--", url,
 		)
-		files, err2 := runYtDlpAndCollectFiles(ctx, cmd, compatArgs, jobDir)
+		files, known, err2 := runYtDlpAndCollectFiles(ctx, cmd, compatArgs, jobDir)
 		if err2 == nil && len(files) > 0 {
-			return &model.DownloadResult{Files: files, Size: totalSize(files)}, nil
+			return newResult(files, known), nil
 		}
 		if err2 != nil {
 			return nil, err2
@@ -374,7 +382,8 @@ except Exception as e:
 	if len(files) == 0 {
 		return nil, fmt.Errorf("instaloader produced no images")
 	}
-	return &model.DownloadResult{Files: files, Size: totalSize(files)}, nil
+	// instaloader doesn't report its CDN URLs; the files describe themselves.
+	return newResult(files, nil), nil
 }
 
 // instaloaderModuleMissing reports whether a Python run failed because the
@@ -401,9 +410,9 @@ func extractInstagramShortcode(link string) string {
 func filterImages(files []string) []string {
 	out := make([]string, 0, len(files))
 	for _, f := range files {
-		ext := strings.ToLower(filepath.Ext(f))
-		switch ext {
-		case ".jpg", ".jpeg", ".png", ".webp":
+		// Judge by content: instaloader also writes .txt/.json sidecars, and
+		// a CDN may serve WebP/AVIF under any extension.
+		if format, ok := media.Sniff(f); ok && format.Kind == "image" {
 			out = append(out, f)
 		}
 	}
@@ -462,19 +471,26 @@ func detectImpersonateTarget(cmd string) string {
 	return ""
 }
 
-func runYtDlpAndCollectFiles(ctx context.Context, cmd string, args []string, jobDir string) ([]string, error) {
+// ytPrintTemplate makes yt-dlp print, per finished file, its path plus what it
+// knows about the media (tab separated; merged formats have no single url,
+// single videos no playlist index).
+const ytPrintTemplate = "after_move:%(filepath)s\t%(url|)s\t%(width|0)s\t%(height|0)s\t%(duration|0)s\t%(playlist_index|0)s"
+
+func runYtDlpAndCollectFiles(ctx context.Context, cmd string, args []string, jobDir string) ([]string, map[string]model.MediaFile, error) {
 	res, err := execx.Run(ctx, cmd, args...)
 	if err != nil {
 		out := strings.TrimSpace(res.Output)
 		if out != "" {
-			return nil, fmt.Errorf("%w: %s", err, out)
+			return nil, nil, fmt.Errorf("%w: %s", err, out)
 		}
-		return nil, err
+		return nil, nil, err
 	}
-	// With --print after_move:filepath, yt-dlp prints one path per line.
+	// With --print ytPrintTemplate, yt-dlp prints one line per file.
 	var files []string
+	known := map[string]model.MediaFile{}
 	for _, line := range strings.Split(res.Output, "\n") {
-		p := strings.TrimSpace(line)
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		p := strings.TrimSpace(fields[0])
 		if p == "" {
 			continue
 		}
@@ -483,17 +499,39 @@ func runYtDlpAndCollectFiles(ctx context.Context, cmd string, args []string, job
 		}
 		if st, statErr := os.Stat(p); statErr == nil && !st.IsDir() {
 			files = append(files, p)
+			known[p] = ytMediaFile(fields)
 		}
 	}
 	if len(files) > 0 {
 		sort.Strings(files)
-		return files, nil
+		// Post order when yt-dlp reported playlist indexes.
+		sort.SliceStable(files, func(i, j int) bool { return known[files[i]].Position < known[files[j]].Position })
+		return files, known, nil
 	}
 	// Fallback: directory walk (covers cases where --print isn't emitted).
 	files = allFiles(jobDir)
 	if len(files) == 0 {
-		return nil, nil
+		return nil, nil, nil
+	}
+	return files, nil, nil
+}
+
+// ytMediaFile reads the metadata columns of a ytPrintTemplate line.
+func ytMediaFile(fields []string) model.MediaFile {
+	var mf model.MediaFile
+	col := func(i int) string {
+		if i < len(fields) && fields[i] != "NA" {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+	if u := col(1); strings.HasPrefix(u, "http") {
+		mf.SourceURL = u
 	}
-	return files, nil
+	mf.Width, _ = strconv.Atoi(col(2))
+	mf.Height, _ = strconv.Atoi(col(3))
+	mf.Duration, _ = strconv.ParseFloat(col(4), 64)
+	mf.Position, _ = strconv.Atoi(col(5))
+	return mf
 }
 
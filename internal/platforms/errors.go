@@ -1,8 +1,39 @@
 package platforms
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // ErrEngineUnavailable indicates the engine cannot run in the current environment
 // (e.g. missing python binary). The pipeline should skip retries for this engine.
 var ErrEngineUnavailable = errors.New("engine unavailable")
 
+// ErrLoginRequired indicates the content is only served to a logged-in account
+// (private profile, stories) and no usable cookies were provided for it.
+var ErrLoginRequired = errors.New("login required")
+
+// ErrNoMedia indicates the post exists but has nothing to download (e.g. a
+// text-only Threads post). It is an answer, not an engine failure.
+var ErrNoMedia = errors.New("post has no media")
+
+// HandoffError is returned by an engine that finds the post belongs to another
+// engine (tiktok-native landing on a photo post): the pipeline skips straight
+// to Engine instead of running the ones in between. It is not an engine failure.
+type HandoffError struct {
+	Engine string
+	Reason string
+}
+
+func (e *HandoffError) Error() string {
+	return fmt.Sprintf("%s; handing off to %s", e.Reason, e.Engine)
+}
+
+// HandoffTarget returns the engine err hands the post to, or "" if it doesn't.
+func HandoffTarget(err error) string {
+	var h *HandoffError
+	if errors.As(err, &h) {
+		return h.Engine
+	}
+	return ""
+}
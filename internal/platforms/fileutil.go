@@ -4,6 +4,9 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+
+	"telegram_bot_downloader/internal/media"
+	"telegram_bot_downloader/internal/model"
 )
 
 func allFiles(dir string) []string {
@@ -28,3 +31,20 @@ func totalSize(files []string) int64 {
 	return sum
 }
 
+// newResult builds an engine's DownloadResult: known holds what the engine
+// learned about each file (source URL, dimensions, duration, post position),
+// keyed by path; kind, MIME and size are filled in from the files themselves,
+// and the position from the file order when the engine didn't give one.
+func newResult(files []string, known map[string]model.MediaFile) *model.DownloadResult {
+	items := make([]model.MediaFile, len(files))
+	for i, f := range files {
+		mf := known[f]
+		mf.Path = f
+		if mf.Position == 0 {
+			mf.Position = i + 1
+		}
+		media.Describe(&mf)
+		items[i] = mf
+	}
+	return &model.DownloadResult{Files: files, Size: totalSize(files), Items: items}
+}
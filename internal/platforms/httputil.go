@@ -0,0 +1,65 @@
+package platforms
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"time"
+)
+
+// NewKeepAliveClient builds a shared client for the native engines (and the
+// native detector): a pooled keep-alive transport so warm requests skip the
+// TLS handshake, and a cookie jar when the site hands out cookies its CDN or
+// later API calls check.
+func NewKeepAliveClient(timeout time.Duration, withJar bool) *http.Client {
+	c := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			MaxIdleConns:        20,
+			MaxIdleConnsPerHost: 8,
+			IdleConnTimeout:     90 * time.Second,
+			ForceAttemptHTTP2:   true,
+		},
+	}
+	if withJar {
+		c.Jar, _ = cookiejar.New(nil)
+	}
+	return c
+}
+
+// downloadTo fetches a direct media URL to dst over client with the browser
+// User-Agent plus headers (e.g. the Referer a CDN checks), removing a partial
+// file on error.
+func downloadTo(ctx context.Context, client *http.Client, mediaURL, dst string, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", browserUA)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("cdn http %d", resp.StatusCode)
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(dst) // don't leave a truncated file behind
+	}
+	return err
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"telegram_bot_downloader/internal/execx"
@@ -39,6 +41,8 @@ func (FastInstagramEngine) Name() string { return "instagram-fast" }
 // photos, carousels) and doc_id-INDEPENDENT — measured faster than the graphql
 // path (which needs a separate CSRF preflight and "execution error"s on many
 // non-reel posts), and it can't be broken by Instagram rotating its doc_id.
+// Optional arg 3 is a comma-separated list of 1-based items to fetch. An item
+// whose CDN fetch fails is reported as "MISSING\t<pos>" and skipped.
 // Exit 3 = curl_cffi unavailable (try the next interpreter); 2 = a real failure
 // (fall back to the next engine).
 const igFastScript = `
@@ -49,6 +53,7 @@ except Exception as e:
     print("NO_CURL_CFFI:", repr(e)); sys.exit(3)
 
 shortcode, target = sys.argv[1], sys.argv[2]
+wanted = set(int(p) for p in sys.argv[3].split(",") if p) if len(sys.argv) > 3 else set()
 s = requests.Session(impersonate="chrome")
 
 def collect(item):
@@ -59,10 +64,12 @@ def collect(item):
         return out
     vv = item.get("video_versions") or []
     if vv:
-        return [(vv[0]["url"], "mp4")]
+        v = vv[0]
+        return [(v["url"], "mp4", v.get("width") or 0, v.get("height") or 0, item.get("video_duration") or 0)]
     cands = ((item.get("image_versions2") or {}).get("candidates")) or []
     if cands:
-        return [(cands[0]["url"], "jpg")]
+        c = cands[0]
+        return [(c["url"], "jpg", c.get("width") or 0, c.get("height") or 0, 0)]
     return []
 
 def find_items(obj, found):
@@ -105,14 +112,24 @@ media = collect(item)
 if not media:
     print("NO_MEDIA_URLS"); sys.exit(2)
 
-for i, (url, ext) in enumerate(media):
+got = 0
+for i, (url, ext, w, h, dur) in enumerate(media):
+    if wanted and i + 1 not in wanted:
+        continue
     dst = os.path.join(target, "%s_%02d.%s" % (shortcode, i, ext))
-    resp = s.get(url, timeout=60)
+    try:
+        resp = s.get(url, timeout=60)
+    except Exception as e:
+        print("CDN_ERROR", i + 1, repr(e)); print("MISSING\t%d" % (i + 1)); continue
     if resp.status_code != 200:
-        print("CDN_HTTP", resp.status_code); sys.exit(2)
+        print("CDN_HTTP", resp.status_code); print("MISSING\t%d" % (i + 1)); continue
     with open(dst, "wb") as f:
         f.write(resp.content)
-print("OK", len(media))
+    got += 1
+    print("ITEM\t%s\t%s\t%d\t%d\t%s\t%d" % (os.path.basename(dst), url, w, h, dur, i + 1))
+if got == 0:
+    print("NO_ITEMS_DOWNLOADED"); sys.exit(2)
+print("OK", got)
 `
 
 func (e FastInstagramEngine) Download(ctx context.Context, url string, jobDir string, opts Options) (*model.DownloadResult, error) {
@@ -121,13 +138,19 @@ func (e FastInstagramEngine) Download(ctx context.Context, url string, jobDir st
 		return nil, fmt.Errorf("instagram-fast: could not extract shortcode")
 	}
 
+	args := []string{"-c", igFastScript, shortcode, jobDir}
+	if len(opts.Positions) > 0 {
+		args = append(args, joinInts(opts.Positions, ","))
+	}
+
 	var lastOut string
 	var lastErr error
 	ran := false
 	for _, candidate := range resolvePythons(e.Python) {
-		res, err := execx.Run(ctx, candidate, "-c", igFastScript, shortcode, jobDir)
+		res, err := execx.Run(ctx, candidate, args...)
 		out := strings.TrimSpace(res.Output)
 		if err == nil {
+			lastOut = out
 			ran = true
 			lastErr = nil
 			break
@@ -153,7 +176,37 @@ func (e FastInstagramEngine) Download(ctx context.Context, url string, jobDir st
 	if len(files) == 0 {
 		return nil, fmt.Errorf("instagram-fast: produced no files")
 	}
-	return &model.DownloadResult{Files: files, Size: totalSize(files)}, nil
+	known, missing := igFastItems(lastOut, jobDir)
+	res := newResult(files, known)
+	res.Missing = missing
+	return res, nil
+}
+
+func (FastInstagramEngine) SupportsPositions() bool { return true }
+
+// igFastItems reads the script's
+// "ITEM\t<name>\t<url>\t<w>\t<h>\t<duration>\t<position>" lines into per-file
+// metadata and its "MISSING\t<position>" lines into the missing positions.
+func igFastItems(out, jobDir string) (map[string]model.MediaFile, []int) {
+	known := map[string]model.MediaFile{}
+	var missing []int
+	for _, line := range strings.Split(out, "\n") {
+		f := strings.Split(strings.TrimSpace(line), "\t")
+		switch {
+		case len(f) == 7 && f[0] == "ITEM":
+			mf := model.MediaFile{SourceURL: f[2]}
+			mf.Width, _ = strconv.Atoi(f[3])
+			mf.Height, _ = strconv.Atoi(f[4])
+			mf.Duration, _ = strconv.ParseFloat(f[5], 64)
+			mf.Position, _ = strconv.Atoi(f[6])
+			known[filepath.Join(jobDir, filepath.Base(f[1]))] = mf
+		case len(f) == 2 && f[0] == "MISSING":
+			if pos, err := strconv.Atoi(f[1]); err == nil {
+				missing = append(missing, pos)
+			}
+		}
+	}
+	return known, missing
 }
 
 // resolvePythons returns the python interpreters to try, existence-filtered. The
@@ -6,9 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
-	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -24,9 +22,11 @@ import (
 // endpoints get soft-blocked on a cloud IP, which is the bulk of the ~3.3s).
 //
 // Measured (live): ~0.7s cold / ~0.25s warm to resolve, vs yt-dlp's ~3.3s. It
-// handles reels (video), single photos, and carousels. On ANY failure it returns
-// an error so the pipeline falls back to yt-dlp / instaloader — so the bot never
-// breaks, it just gets slower until the native path works again.
+// handles reels (video), single photos, and carousels. If the post can't be
+// resolved it returns an error so the pipeline falls back to yt-dlp / instaloader
+// — so the bot never breaks, it just gets slower until the native path works
+// again. Carousel items whose CDN fetch fails are reported in Missing instead,
+// and the pipeline fetches only those from the next engine.
 //
 // MAINTENANCE: Instagram rotates the doc_id every ~2-4 weeks as anti-scraping
 // (it just moved 8845758582119845 -> 27128499623469141). When it rotates, the
@@ -56,17 +56,7 @@ var (
 // jar) so warm requests skip the TLS handshake and reuse the csrf/mid cookies.
 func igHTTP() *http.Client {
 	igOnce.Do(func() {
-		jar, _ := cookiejar.New(nil)
-		igClient = &http.Client{
-			Timeout: 20 * time.Second,
-			Jar:     jar,
-			Transport: &http.Transport{
-				MaxIdleConns:        20,
-				MaxIdleConnsPerHost: 8,
-				IdleConnTimeout:     90 * time.Second,
-				ForceAttemptHTTP2:   true,
-			},
-		}
+		igClient = NewKeepAliveClient(20*time.Second, true)
 	})
 	return igClient
 }
@@ -129,12 +119,15 @@ func igCSRFToken(ctx context.Context) (string, error) {
 
 // igCandidate is one media rendition (video or image).
 type igCandidate struct {
-	URL string `json:"url"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
 }
 
 // igItem is one media item; media_type 1=image, 2=video, 8=carousel.
 type igItem struct {
 	MediaType      int           `json:"media_type"`
+	VideoDuration  float64       `json:"video_duration"`
 	VideoVersions  []igCandidate `json:"video_versions"`
 	ImageVersions2 struct {
 		Candidates []igCandidate `json:"candidates"`
@@ -151,8 +144,11 @@ type igResp struct {
 }
 
 type igMedia struct {
-	url     string
-	isVideo bool
+	url      string
+	isVideo  bool
+	width    int
+	height   int
+	duration float64
 }
 
 // collectIGMedia flattens a post item into a downloadable URL list (videos
@@ -165,17 +161,15 @@ func collectIGMedia(item igItem) []igMedia {
 			out = append(out, collectIGMedia(c)...)
 		}
 		return out
-	case 2: // video
-		if len(item.VideoVersions) > 0 {
-			return []igMedia{{url: item.VideoVersions[0].URL, isVideo: true}}
-		}
 	}
-	// image (type 1) or a video item that only exposed images
+	// video (type 2), image (type 1), or a video item that only exposed images
 	if len(item.VideoVersions) > 0 {
-		return []igMedia{{url: item.VideoVersions[0].URL, isVideo: true}}
+		v := item.VideoVersions[0]
+		return []igMedia{{url: v.URL, isVideo: true, width: v.Width, height: v.Height, duration: item.VideoDuration}}
 	}
 	if len(item.ImageVersions2.Candidates) > 0 {
-		return []igMedia{{url: item.ImageVersions2.Candidates[0].URL, isVideo: false}}
+		c := item.ImageVersions2.Candidates[0]
+		return []igMedia{{url: c.URL, isVideo: false, width: c.Width, height: c.Height}}
 	}
 	return out
 }
@@ -239,43 +233,36 @@ func (e NativeInstagramEngine) Download(ctx context.Context, rawURL string, jobD
 	}
 
 	var files []string
+	var missing []int
+	var lastErr error
+	known := map[string]model.MediaFile{}
 	for i, m := range media {
+		if !wantPosition(opts.Positions, i+1) {
+			continue
+		}
 		ext := ".jpg"
 		if m.isVideo {
 			ext = ".mp4"
 		}
 		dst := filepath.Join(jobDir, fmt.Sprintf("%s_%02d%s", shortcode, i, ext))
-		if err := igDownloadTo(ctx, m.url, dst); err != nil {
-			// A partial carousel is worse than a clean fallback.
-			return nil, fmt.Errorf("instagram-native: download item %d: %w", i, err)
+		if err := downloadTo(ctx, igHTTP(), m.url, dst, nil); err != nil {
+			// Keep going: the pipeline fills the gap from the next engine.
+			missing = append(missing, i+1)
+			lastErr = fmt.Errorf("instagram-native: download item %d: %w", i+1, err)
+			continue
 		}
 		files = append(files, dst)
+		known[dst] = model.MediaFile{SourceURL: m.url, Width: m.width, Height: m.height, Duration: m.duration, Position: i + 1}
 	}
-	return &model.DownloadResult{Files: files, Size: totalSize(files)}, nil
-}
-
-// igDownloadTo fetches a direct CDN URL to disk over the shared keep-alive client.
-func igDownloadTo(ctx context.Context, mediaURL, dst string) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaURL, nil)
-	if err != nil {
-		return err
-	}
-	req.Header.Set("User-Agent", browserUA)
-	resp, err := igHTTP().Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("cdn http %d", resp.StatusCode)
-	}
-	f, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	if _, err := io.Copy(f, resp.Body); err != nil {
-		return err
+	if len(files) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("instagram-native: none of the requested items exist")
+		}
+		return nil, lastErr
 	}
-	return nil
+	res := newResult(files, known)
+	res.Missing = missing
+	return res, nil
 }
+
+func (NativeInstagramEngine) SupportsPositions() bool { return true }
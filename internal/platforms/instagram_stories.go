@@ -0,0 +1,185 @@
+package platforms
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"telegram_bot_downloader/internal/model"
+)
+
+// InstagramStoriesEngine downloads stories and highlights, which Instagram only
+// serves to a logged-in account: it calls the reels_media API with the
+// Instagram cookie session (INSTAGRAM_COOKIES_B64 -> CookiesPathForURL). A
+// story link (/stories/<user>/<id>/) yields that one item, a user's story link
+// without an id their whole current story, and a highlight link
+// (/stories/highlights/<id>/ or an /s/ share link) the whole highlight reel.
+// Without a session it fails with ErrLoginRequired.
+type InstagramStoriesEngine struct{}
+
+func (InstagramStoriesEngine) Name() string { return "instagram-stories" }
+
+func (InstagramStoriesEngine) SupportsPositions() bool { return true }
+
+var (
+	igHighlightRe = regexp.MustCompile(`instagram\.com/stories/highlights/(\d+)`)
+	igStoryRe     = regexp.MustCompile(`instagram\.com/stories/([A-Za-z0-9._]+)(?:/(\d+))?`)
+	igShareRe     = regexp.MustCompile(`instagram\.com/s/([A-Za-z0-9_=-]+)`)
+)
+
+// igStoryRef is what a stories link points at.
+type igStoryRef struct {
+	highlight string // highlight id
+	username  string // story owner (user stories)
+	mediaID   string // one item of the reel; "" = all of it
+}
+
+// parseIGStoryURL recognises story, highlight and /s/ share links.
+func parseIGStoryURL(raw string) (igStoryRef, bool) {
+	var ref igStoryRef
+	if u, err := url.Parse(raw); err == nil {
+		// Share links carry the item as story_media_id=<pk>_<owner id>.
+		ref.mediaID, _, _ = strings.Cut(u.Query().Get("story_media_id"), "_")
+	}
+	if m := igHighlightRe.FindStringSubmatch(raw); m != nil {
+		ref.highlight = m[1]
+		return ref, true
+	}
+	if m := igShareRe.FindStringSubmatch(raw); m != nil {
+		// /s/ + base64("highlight:<id>")
+		dec, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(m[1], "="))
+		if err != nil {
+			dec, err = base64.StdEncoding.DecodeString(m[1])
+		}
+		if id, ok := strings.CutPrefix(string(dec), "highlight:"); err == nil && ok && id != "" {
+			ref.highlight = id
+			return ref, true
+		}
+		return igStoryRef{}, false
+	}
+	if m := igStoryRe.FindStringSubmatch(raw); m != nil && m[1] != "highlights" {
+		ref.username = m[1]
+		if m[2] != "" {
+			ref.mediaID = m[2]
+		}
+		return ref, true
+	}
+	return igStoryRef{}, false
+}
+
+// IsInstagramStoryURL reports whether link is a story or highlight link.
+func IsInstagramStoryURL(link string) bool {
+	_, ok := parseIGStoryURL(link)
+	return ok
+}
+
+// igStoryItem is a reels_media item: an igItem plus its id ("<pk>_<owner>").
+type igStoryItem struct {
+	igItem
+	ID string          `json:"id"`
+	PK json.RawMessage `json:"pk"` // a number or a string depending on the API version
+}
+
+type igReelsMediaResp struct {
+	Reels map[string]struct {
+		Items []igStoryItem `json:"items"`
+	} `json:"reels"`
+	ReelsMedia []struct {
+		Items []igStoryItem `json:"items"`
+	} `json:"reels_media"`
+}
+
+func (e InstagramStoriesEngine) Download(ctx context.Context, rawURL string, jobDir string, opts Options) (*model.DownloadResult, error) {
+	ref, ok := parseIGStoryURL(rawURL)
+	if !ok {
+		return nil, fmt.Errorf("instagram-stories: not a story or highlight link")
+	}
+	cookies := readCookieFile(CookiesPathForURL(rawURL))
+	if !hasCookie(cookies, "sessionid") {
+		return nil, fmt.Errorf("instagram-stories: no Instagram session (set INSTAGRAM_COOKIES_B64): %w", ErrLoginRequired)
+	}
+
+	reelID := "highlight:" + ref.highlight
+	if ref.highlight == "" {
+		var prof igProfileResp
+		if err := igGetJSON(ctx, "https://i.instagram.com/api/v1/users/web_profile_info/?username="+url.QueryEscape(ref.username), cookies, &prof); err != nil {
+			return nil, fmt.Errorf("instagram-stories: %w", err)
+		}
+		if prof.Data.User == nil || prof.Data.User.ID == "" {
+			return nil, fmt.Errorf("instagram-stories: user %q not found", ref.username)
+		}
+		reelID = prof.Data.User.ID
+	}
+
+	var r igReelsMediaResp
+	if err := igGetJSON(ctx, "https://i.instagram.com/api/v1/feed/reels_media/?reel_ids="+url.QueryEscape(reelID), cookies, &r); err != nil {
+		return nil, fmt.Errorf("instagram-stories: %w", err)
+	}
+	var items []igStoryItem
+	for _, reel := range r.Reels {
+		items = append(items, reel.Items...)
+	}
+	if len(items) == 0 {
+		for _, reel := range r.ReelsMedia {
+			items = append(items, reel.Items...)
+		}
+	}
+	if ref.mediaID != "" {
+		var one []igStoryItem
+		for _, it := range items {
+			if strings.HasPrefix(it.ID, ref.mediaID) || strings.Trim(string(it.PK), `"`) == ref.mediaID {
+				one = append(one, it)
+			}
+		}
+		if len(one) == 0 {
+			// Stories expire after 24h; the rest of the reel isn't what was asked for.
+			return nil, fmt.Errorf("instagram-stories: story %s not found (expired?)", ref.mediaID)
+		}
+		items = one
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("instagram-stories: no story items (expired or empty reel)")
+	}
+
+	var files []string
+	var missing []int
+	var lastErr error
+	known := map[string]model.MediaFile{}
+	for i, it := range items {
+		if !wantPosition(opts.Positions, i+1) {
+			continue
+		}
+		media := collectIGMedia(it.igItem)
+		if len(media) == 0 {
+			missing = append(missing, i+1)
+			continue
+		}
+		m := media[0]
+		ext := ".jpg"
+		if m.isVideo {
+			ext = ".mp4"
+		}
+		dst := filepath.Join(jobDir, fmt.Sprintf("story_%02d%s", i, ext))
+		if err := downloadTo(ctx, igHTTP(), m.url, dst, nil); err != nil {
+			missing = append(missing, i+1)
+			lastErr = fmt.Errorf("instagram-stories: download item %d: %w", i+1, err)
+			continue
+		}
+		files = append(files, dst)
+		known[dst] = model.MediaFile{SourceURL: m.url, Width: m.width, Height: m.height, Duration: m.duration, Position: i + 1}
+	}
+	if len(files) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("instagram-stories: no downloadable media in the reel")
+		}
+		return nil, lastErr
+	}
+	res := newResult(files, known)
+	res.Missing = missing
+	return res, nil
+}
@@ -0,0 +1,337 @@
+package platforms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram_bot_downloader/internal/media"
+	"telegram_bot_downloader/internal/model"
+)
+
+// PinterestEngine downloads pins from Pinterest's own PinResource JSON (what the
+// web app loads): the original-size image (yt-dlp often returns a 236x/736x
+// thumbnail), the video's best MP4 rendition within the height cap (HLS through
+// ffmpeg when there is none), and every page of a carousel or story (idea) pin
+// in order. pin.it short links are expanded by following their redirects. A
+// pin it finds nothing in fails with an ordinary error so yt-dlp still runs.
+type PinterestEngine struct{}
+
+func (PinterestEngine) Name() string { return "pinterest" }
+
+func (PinterestEngine) SupportsPositions() bool { return true }
+
+var (
+	pnOnce       sync.Once
+	pnClient     *http.Client
+	pnCDNHeaders = map[string]string{"Referer": "https://www.pinterest.com/"}
+
+	pnPinRe = regexp.MustCompile(`pinterest\.[a-z.]+/pin/(?:[^/?#]*--)?(\d+)`)
+)
+
+// pnHTTP returns the shared keep-alive Pinterest client (resource API and the
+// i.pinimg.com / v1.pinimg.com CDNs).
+func pnHTTP() *http.Client {
+	pnOnce.Do(func() {
+		pnClient = NewKeepAliveClient(60*time.Second, false)
+	})
+	return pnClient
+}
+
+type pnImage struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+type pnVideo struct {
+	URL      string  `json:"url"`
+	Width    int     `json:"width"`
+	Height   int     `json:"height"`
+	Duration float64 `json:"duration"` // milliseconds
+}
+
+type pnVideos struct {
+	VideoList map[string]pnVideo `json:"video_list"`
+}
+
+type pnPin struct {
+	ID           string             `json:"id"`
+	Images       map[string]pnImage `json:"images"`
+	Videos       *pnVideos          `json:"videos"`
+	CarouselData *struct {
+		Slots []struct {
+			Images map[string]pnImage `json:"images"`
+		} `json:"carousel_slots"`
+	} `json:"carousel_data"`
+	StoryPinData *struct {
+		Pages []struct {
+			Blocks []struct {
+				Image *struct {
+					Images map[string]pnImage `json:"images"`
+				} `json:"image"`
+				Video *pnVideos `json:"video"`
+			} `json:"blocks"`
+		} `json:"pages"`
+	} `json:"story_pin_data"`
+}
+
+// pnItem is one downloadable item of a pin: an image or a video rendition list.
+type pnItem struct {
+	image  pnImage
+	videos map[string]pnVideo
+}
+
+func (e PinterestEngine) Download(ctx context.Context, rawURL string, jobDir string, opts Options) (*model.DownloadResult, error) {
+	id, err := pnPinID(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	pin, err := pnFetchPin(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	items := collectPinMedia(pin)
+	if len(items) == 0 {
+		// Every pin has an image; finding none means the JSON changed shape,
+		// so let yt-dlp try (and the breaker count it) rather than give up.
+		return nil, fmt.Errorf("pinterest: pin %s: no media in the pin resource", id)
+	}
+	maxH, _ := strconv.Atoi(strings.TrimSpace(opts.MaxHeight))
+
+	var files []string
+	var missing []int
+	var lastErr error
+	known := map[string]model.MediaFile{}
+	for i, it := range items {
+		if !wantPosition(opts.Positions, i+1) {
+			continue
+		}
+		base := filepath.Join(jobDir, fmt.Sprintf("%s_%02d", id, i))
+		mf, err := pnDownloadItem(ctx, it, base, maxH)
+		if err != nil {
+			missing = append(missing, i+1)
+			lastErr = fmt.Errorf("pinterest: download item %d: %w", i+1, err)
+			continue
+		}
+		mf.Position = i + 1
+		files = append(files, mf.Path)
+		known[mf.Path] = mf
+	}
+	if len(files) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("pinterest: none of the requested items exist")
+		}
+		return nil, lastErr
+	}
+	res := newResult(files, known)
+	res.Missing = missing
+	return res, nil
+}
+
+// pnPinID returns the pin id of a pin link; pin.it short links are followed
+// until a hop lands on a /pin/<id>/ URL.
+func pnPinID(ctx context.Context, rawURL string) (string, error) {
+	if m := pnPinRe.FindStringSubmatch(rawURL); m != nil {
+		return m[1], nil
+	}
+	var id string
+	c := *pnHTTP()
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if m := pnPinRe.FindStringSubmatch(req.URL.String()); m != nil {
+			id = m[1]
+			return http.ErrUseLastResponse
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", browserUA)
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if id == "" {
+		if m := pnPinRe.FindStringSubmatch(resp.Request.URL.String()); m != nil {
+			id = m[1]
+		}
+	}
+	if id == "" {
+		return "", fmt.Errorf("pinterest: could not resolve %s to a pin (http %d)", rawURL, resp.StatusCode)
+	}
+	return id, nil
+}
+
+// pnFetchPin loads a pin from the PinResource endpoint.
+func pnFetchPin(ctx context.Context, id string) (pnPin, error) {
+	data, _ := json.Marshal(map[string]any{
+		"options": map[string]any{"id": id, "field_set_key": "unauth_react_main_pin"},
+		"context": map[string]any{},
+	})
+	q := url.Values{"source_url": {"/pin/" + id + "/"}, "data": {string(data)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://www.pinterest.com/resource/PinResource/get/?"+q.Encode(), nil)
+	if err != nil {
+		return pnPin{}, err
+	}
+	req.Header.Set("User-Agent", browserUA)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Pinterest-PWS-Handler", "www/pin/[id].js")
+	if h := cookieHeader(loadCookies("pinterest"), "pinterest.com"); h != "" {
+		req.Header.Set("Cookie", h)
+	}
+	resp, err := pnHTTP().Do(req)
+	if err != nil {
+		return pnPin{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return pnPin{}, fmt.Errorf("pinterest: pin resource http %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return pnPin{}, err
+	}
+	var r struct {
+		ResourceResponse struct {
+			Data *pnPin `json:"data"`
+		} `json:"resource_response"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return pnPin{}, fmt.Errorf("pinterest: decode: %w", err)
+	}
+	if r.ResourceResponse.Data == nil {
+		return pnPin{}, fmt.Errorf("pinterest: pin %s not found (removed or private)", id)
+	}
+	return *r.ResourceResponse.Data, nil
+}
+
+// collectPinMedia lists a pin's items in display order: carousel slots, story
+// pin pages (a page's video over its cover image), else the pin's video or
+// image.
+func collectPinMedia(p pnPin) []pnItem {
+	var out []pnItem
+	if p.CarouselData != nil && len(p.CarouselData.Slots) > 1 {
+		for _, s := range p.CarouselData.Slots {
+			out = append(out, pnItem{image: pnBestImage(s.Images)})
+		}
+		return out
+	}
+	if p.StoryPinData != nil {
+		for _, page := range p.StoryPinData.Pages {
+			var it pnItem
+			for _, b := range page.Blocks {
+				switch {
+				case b.Video != nil && len(b.Video.VideoList) > 0:
+					it.videos = b.Video.VideoList
+				case b.Image != nil && it.image.URL == "":
+					it.image = pnBestImage(b.Image.Images)
+				}
+			}
+			if it.videos != nil || it.image.URL != "" {
+				out = append(out, it)
+			}
+		}
+		if len(out) > 0 {
+			return out
+		}
+	}
+	if p.Videos != nil && len(p.Videos.VideoList) > 0 {
+		return []pnItem{{videos: p.Videos.VideoList}}
+	}
+	if img := pnBestImage(p.Images); img.URL != "" {
+		return []pnItem{{image: img}}
+	}
+	return nil
+}
+
+// pnBestImage picks the original ("orig" / "originals"), else the widest size.
+func pnBestImage(images map[string]pnImage) pnImage {
+	for _, k := range []string{"orig", "originals"} {
+		if img, ok := images[k]; ok && img.URL != "" {
+			return img
+		}
+	}
+	var best pnImage
+	for _, img := range images {
+		if img.URL != "" && img.Width > best.Width {
+			best = img
+		}
+	}
+	return best
+}
+
+// pnPickVideo picks the tallest MP4 rendition whose short side is within maxH
+// (0 = no cap), else the smallest MP4; hls is set when the pin only has an HLS
+// playlist.
+func pnPickVideo(list map[string]pnVideo, maxH int) (v pnVideo, hls bool) {
+	var fit, small, playlist pnVideo
+	for _, r := range list {
+		if r.URL == "" {
+			continue
+		}
+		u, err := url.Parse(r.URL)
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(path.Ext(u.Path)) {
+		case ".m3u8":
+			playlist = r
+		case ".mp4":
+			side := min(r.Width, r.Height)
+			if (maxH <= 0 || side <= maxH) && side > min(fit.Width, fit.Height) {
+				fit = r
+			}
+			if small.URL == "" || side < min(small.Width, small.Height) {
+				small = r
+			}
+		}
+	}
+	switch {
+	case fit.URL != "":
+		return fit, false
+	case small.URL != "":
+		return small, false
+	default:
+		return playlist, playlist.URL != ""
+	}
+}
+
+// pnDownloadItem fetches one item to base + its extension.
+func pnDownloadItem(ctx context.Context, it pnItem, base string, maxH int) (model.MediaFile, error) {
+	if it.videos != nil {
+		v, hls := pnPickVideo(it.videos, maxH)
+		if v.URL == "" {
+			return model.MediaFile{}, fmt.Errorf("no video rendition")
+		}
+		mf := model.MediaFile{Path: base + ".mp4", SourceURL: v.URL, Width: v.Width, Height: v.Height, Duration: v.Duration / 1000}
+		if hls {
+			return mf, media.FetchHLS(ctx, v.URL, mf.Path)
+		}
+		return mf, downloadTo(ctx, pnHTTP(), v.URL, mf.Path, pnCDNHeaders)
+	}
+	ext := ".jpg"
+	if u, err := url.Parse(it.image.URL); err == nil {
+		if e := strings.ToLower(path.Ext(u.Path)); e != "" {
+			ext = e
+		}
+	}
+	mf := model.MediaFile{Path: base + ext, SourceURL: it.image.URL, Width: it.image.Width, Height: it.image.Height}
+	return mf, downloadTo(ctx, pnHTTP(), it.image.URL, mf.Path, pnCDNHeaders)
+}
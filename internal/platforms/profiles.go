@@ -0,0 +1,287 @@
+package platforms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"telegram_bot_downloader/internal/execx"
+	"telegram_bot_downloader/internal/urlx"
+)
+
+// ProfileLister lists the post URLs of an account's latest posts, newest
+// first, for bulk downloads. Each URL then goes through the normal pipeline.
+type ProfileLister interface {
+	ListProfile(ctx context.Context, p urlx.Profile, limit int) ([]string, error)
+}
+
+// ProfileListerFor returns the lister for a profile's platform (nil when the
+// platform has none).
+func (r Registry) ProfileListerFor(platform string) ProfileLister {
+	return r.Profiles[platform]
+}
+
+// InstagramProfileLister reads the profile's first page of posts from
+// web_profile_info (12 posts, works anonymously for public accounts) and, when
+// Instagram cookies are loaded, pages further through the logged-in user feed.
+type InstagramProfileLister struct{}
+
+type igProfileResp struct {
+	Data struct {
+		User *struct {
+			ID        string `json:"id"`
+			IsPrivate bool   `json:"is_private"`
+			Timeline  struct {
+				Edges []struct {
+					Node struct {
+						Shortcode string `json:"shortcode"`
+						IsVideo   bool   `json:"is_video"`
+					} `json:"node"`
+				} `json:"edges"`
+				PageInfo struct {
+					HasNextPage bool `json:"has_next_page"`
+				} `json:"page_info"`
+			} `json:"edge_owner_to_timeline_media"`
+		} `json:"user"`
+	} `json:"data"`
+}
+
+type igFeedResp struct {
+	Items []struct {
+		Code      string `json:"code"`
+		MediaType int    `json:"media_type"`
+	} `json:"items"`
+	MoreAvailable bool   `json:"more_available"`
+	NextMaxID     string `json:"next_max_id"`
+}
+
+func (InstagramProfileLister) ListProfile(ctx context.Context, p urlx.Profile, limit int) ([]string, error) {
+	cookies := loadCookies("instagram")
+	var r igProfileResp
+	endpoint := "https://i.instagram.com/api/v1/users/web_profile_info/?username=" + url.QueryEscape(p.Handle)
+	if err := igGetJSON(ctx, endpoint, cookies, &r); err != nil {
+		return nil, err
+	}
+	user := r.Data.User
+	if user == nil {
+		return nil, fmt.Errorf("instagram: profile %q not found", p.Handle)
+	}
+	if user.IsPrivate && len(user.Timeline.Edges) == 0 {
+		return nil, fmt.Errorf("instagram: profile %q is private: %w", p.Handle, ErrLoginRequired)
+	}
+
+	var out []string
+	seen := map[string]bool{}
+	add := func(code string, video bool) {
+		if code == "" || seen[code] || len(out) >= limit || (p.VideosOnly && !video) {
+			return
+		}
+		seen[code] = true
+		if video && p.VideosOnly {
+			out = append(out, "https://www.instagram.com/reel/"+code+"/")
+		} else {
+			out = append(out, "https://www.instagram.com/p/"+code+"/")
+		}
+	}
+	for _, e := range user.Timeline.Edges {
+		add(e.Node.Shortcode, e.Node.IsVideo)
+	}
+
+	// Past the first page only a logged-in session is served.
+	if len(out) >= limit || !user.Timeline.PageInfo.HasNextPage || len(cookies) == 0 {
+		return out, nil
+	}
+	maxID := ""
+	for page := 0; page < 10 && len(out) < limit; page++ {
+		var feed igFeedResp
+		endpoint := "https://www.instagram.com/api/v1/feed/user/" + user.ID + "/?count=33"
+		if maxID != "" {
+			endpoint += "&max_id=" + url.QueryEscape(maxID)
+		}
+		if err := igGetJSON(ctx, endpoint, cookies, &feed); err != nil {
+			if len(out) > 0 {
+				return out, nil // keep what the first page gave
+			}
+			return nil, err
+		}
+		for _, it := range feed.Items {
+			add(it.Code, it.MediaType == 2)
+		}
+		if !feed.MoreAvailable || feed.NextMaxID == "" {
+			break
+		}
+		maxID = feed.NextMaxID
+	}
+	return out, nil
+}
+
+// igGetJSON GETs an Instagram web API endpoint over the shared client.
+func igGetJSON(ctx context.Context, endpoint string, cookies []*http.Cookie, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", browserUA)
+	req.Header.Set("X-IG-App-ID", igAppID)
+	req.Header.Set("Referer", "https://www.instagram.com/")
+	if h := cookieHeader(cookies, req.URL.Hostname()); h != "" {
+		req.Header.Set("Cookie", h)
+	}
+	resp, err := igHTTP().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("instagram: http %d: %w", resp.StatusCode, ErrLoginRequired)
+	default:
+		return fmt.Errorf("instagram: http %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return err
+	}
+	// Login walls come back as 200 HTML or {"message":"login_required"}.
+	if bytes.Contains(body, []byte(`"login_required"`)) {
+		return fmt.Errorf("instagram: %w", ErrLoginRequired)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("instagram: decode: %w", ErrLoginRequired)
+	}
+	return nil
+}
+
+// TwitterProfileLister reads an account's media tweets from the embedded
+// timeline (syndication.twitter.com), which needs no API token, over the
+// syndication engine's keep-alive client; X often only serves it to a
+// logged-in session, so X cookies are sent when loaded.
+type TwitterProfileLister struct{}
+
+var twNextDataRe = regexp.MustCompile(`(?s)<script id="__NEXT_DATA__" type="application/json">(.*?)</script>`)
+
+type twTimeline struct {
+	Props struct {
+		PageProps struct {
+			Timeline struct {
+				Entries []struct {
+					Type    string `json:"type"`
+					Content struct {
+						Tweet struct {
+							IDStr    string `json:"id_str"`
+							Extended struct {
+								Media []struct {
+									Type string `json:"type"`
+								} `json:"media"`
+							} `json:"extended_entities"`
+						} `json:"tweet"`
+					} `json:"content"`
+				} `json:"entries"`
+			} `json:"timeline"`
+		} `json:"pageProps"`
+	} `json:"props"`
+}
+
+func (TwitterProfileLister) ListProfile(ctx context.Context, p urlx.Profile, limit int) ([]string, error) {
+	endpoint := "https://syndication.twitter.com/srv/timeline-profile/screen-name/" + url.PathEscape(p.Handle)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", browserUA)
+	if h := cookieHeader(loadCookies("twitter"), "twitter.com"); h != "" {
+		req.Header.Set("Cookie", h)
+	}
+	resp, err := twHTTP().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("twitter: timeline http %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return nil, err
+	}
+	m := twNextDataRe.FindSubmatch(body)
+	if m == nil {
+		return nil, fmt.Errorf("twitter: no timeline data for %q", p.Handle)
+	}
+	var tl twTimeline
+	if err := json.Unmarshal(m[1], &tl); err != nil {
+		return nil, fmt.Errorf("twitter: decode timeline: %w", err)
+	}
+	var out []string
+	seen := map[string]bool{}
+	for _, e := range tl.Props.PageProps.Timeline.Entries {
+		t := e.Content.Tweet
+		if e.Type != "tweet" || t.IDStr == "" || len(t.Extended.Media) == 0 || seen[t.IDStr] {
+			continue
+		}
+		seen[t.IDStr] = true
+		out = append(out, "https://x.com/"+p.Handle+"/status/"+t.IDStr)
+		if len(out) >= limit {
+			break
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("twitter: no media tweets for %q: %w", p.Handle, ErrLoginRequired)
+	}
+	return out, nil
+}
+
+// YtDlpProfileLister lists a profile with yt-dlp's flat playlist mode (no
+// media is fetched); used for TikTok.
+type YtDlpProfileLister struct {
+	Cmd         string
+	Impersonate string
+}
+
+func (l YtDlpProfileLister) ListProfile(ctx context.Context, p urlx.Profile, limit int) ([]string, error) {
+	cmd := l.Cmd
+	if cmd == "" {
+		cmd = "yt-dlp"
+	}
+	if p.Platform != "tiktok" {
+		return nil, fmt.Errorf("yt-dlp: no profile listing for %s", p.Platform)
+	}
+	page := "https://www.tiktok.com/@" + p.Handle
+	args := []string{
+		"--no-warnings", "--flat-playlist",
+		"--playlist-end", fmt.Sprintf("%d", limit),
+		"--print", "%(url)s",
+		"--user-agent", browserUA,
+	}
+	if ck := CookiesPathForPlatform(p.Platform); ck != "" {
+		args = append(args, "--cookies", ck)
+	}
+	if t := strings.TrimSpace(l.Impersonate); t != "" {
+		args = append(args, "--impersonate", t)
+	}
+	args = append(args, "--", page)
+	res, err := execx.Run(ctx, cmd, args...)
+	if err != nil {
+		if out := strings.TrimSpace(res.Output); out != "" {
+			return nil, fmt.Errorf("%w: %s", err, firstLine(out))
+		}
+		return nil, err
+	}
+	var out []string
+	for _, line := range strings.Split(res.Output, "\n") {
+		if u := strings.TrimSpace(line); strings.HasPrefix(u, "http") && len(out) < limit {
+			out = append(out, u)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("yt-dlp: no posts listed for %s", page)
+	}
+	return out, nil
+}
@@ -0,0 +1,467 @@
+package platforms
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram_bot_downloader/internal/media"
+	"telegram_bot_downloader/internal/model"
+)
+
+// RedditEngine downloads Reddit posts from the post's public .json endpoint:
+// image galleries (gallery_data + media_metadata, in gallery order), single
+// i.redd.it images and GIFs, and v.redd.it videos. Reddit serves a video's
+// picture and sound as separate DASH tracks, so the two are fetched and muxed
+// with ffmpeg. redd.it short links and /s/ share links are resolved to the post
+// first. Text posts fail with ErrNoMedia; links to other hosts are left to
+// yt-dlp.
+type RedditEngine struct{}
+
+func (RedditEngine) Name() string { return "reddit" }
+
+func (RedditEngine) SupportsPositions() bool { return true }
+
+var (
+	rdOnce       sync.Once
+	rdClient     *http.Client
+	rdCDNHeaders = map[string]string{"Referer": "https://www.reddit.com/"}
+
+	rdCommentsRe = regexp.MustCompile(`reddit\.com/(?:r/[^/]+/|u(?:ser)?/[^/]+/)?comments/([a-z0-9]+)`)
+	rdShortRe    = regexp.MustCompile(`^https?://(?:www\.)?redd\.it/([a-z0-9]+)`)
+)
+
+// rdHTTP returns the shared keep-alive Reddit client. Redirects are followed so
+// /s/ share links and v.redd.it links land on the post.
+func rdHTTP() *http.Client {
+	rdOnce.Do(func() {
+		rdClient = NewKeepAliveClient(60*time.Second, true)
+	})
+	return rdClient
+}
+
+type rdVideo struct {
+	FallbackURL string  `json:"fallback_url"`
+	DashURL     string  `json:"dash_url"`
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	Duration    float64 `json:"duration"`
+	IsGIF       bool    `json:"is_gif"`
+	HasAudio    *bool   `json:"has_audio"` // missing on older posts
+}
+
+type rdMediaMeta struct {
+	Status string `json:"status"`
+	E      string `json:"e"` // "Image" or "AnimatedImage"
+	M      string `json:"m"` // MIME type
+	S      struct {
+		U   string `json:"u"`
+		GIF string `json:"gif"`
+		MP4 string `json:"mp4"`
+		X   int    `json:"x"`
+		Y   int    `json:"y"`
+	} `json:"s"`
+}
+
+type rdPost struct {
+	ID          string `json:"id"`
+	IsSelf      bool   `json:"is_self"`
+	IsVideo     bool   `json:"is_video"`
+	URL         string `json:"url_overridden_by_dest"`
+	PostHint    string `json:"post_hint"`
+	SecureMedia *struct {
+		RedditVideo *rdVideo `json:"reddit_video"`
+	} `json:"secure_media"`
+	GalleryData *struct {
+		Items []struct {
+			MediaID string `json:"media_id"`
+		} `json:"items"`
+	} `json:"gallery_data"`
+	MediaMetadata map[string]rdMediaMeta `json:"media_metadata"`
+	Preview       struct {
+		Images []struct {
+			Source struct {
+				Width  int `json:"width"`
+				Height int `json:"height"`
+			} `json:"source"`
+		} `json:"images"`
+	} `json:"preview"`
+	CrosspostParents []rdPost `json:"crosspost_parent_list"`
+}
+
+type rdListing []struct {
+	Data struct {
+		Children []struct {
+			Data rdPost `json:"data"`
+		} `json:"children"`
+	} `json:"data"`
+}
+
+// rdItem is one downloadable item of a post.
+type rdItem struct {
+	url    string
+	ext    string
+	video  *rdVideo // v.redd.it video (DASH); url/ext unused
+	width  int
+	height int
+	status string // media_metadata status; "" or "valid" is downloadable
+	gif    bool   // an AnimatedImage fetched as its MP4 rendition
+}
+
+func (e RedditEngine) Download(ctx context.Context, rawURL string, jobDir string, opts Options) (*model.DownloadResult, error) {
+	id, err := rdPostID(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	post, err := rdFetchPost(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	items := collectRedditMedia(post)
+	if len(items) == 0 && len(post.CrosspostParents) > 0 {
+		items = collectRedditMedia(post.CrosspostParents[0])
+	}
+	if len(items) == 0 {
+		if post.IsSelf {
+			return nil, fmt.Errorf("reddit: post %s: %w", id, ErrNoMedia)
+		}
+		return nil, fmt.Errorf("reddit: post %s links to %q, not Reddit-hosted media", id, post.URL)
+	}
+	maxH, _ := strconv.Atoi(strings.TrimSpace(opts.MaxHeight))
+
+	var files []string
+	var missing []int
+	var lastErr error
+	known := map[string]model.MediaFile{}
+	for i, it := range items {
+		if !wantPosition(opts.Positions, i+1) {
+			continue
+		}
+		if it.status != "" && it.status != "valid" {
+			missing = append(missing, i+1)
+			lastErr = fmt.Errorf("reddit: item %d is %s", i+1, it.status)
+			continue
+		}
+		base := filepath.Join(jobDir, fmt.Sprintf("%s_%02d", id, i))
+		if it.video != nil {
+			mf, err := rdDownloadVideo(ctx, it.video, base, maxH)
+			if err != nil {
+				missing = append(missing, i+1)
+				lastErr = fmt.Errorf("reddit: video: %w", err)
+				continue
+			}
+			mf.Position = i + 1
+			files = append(files, mf.Path)
+			known[mf.Path] = mf
+			continue
+		}
+		dst := base + it.ext
+		if err := downloadTo(ctx, rdHTTP(), it.url, dst, rdCDNHeaders); err != nil {
+			missing = append(missing, i+1)
+			lastErr = fmt.Errorf("reddit: download item %d: %w", i+1, err)
+			continue
+		}
+		files = append(files, dst)
+		known[dst] = model.MediaFile{SourceURL: it.url, Width: it.width, Height: it.height, Position: i + 1, Animation: it.gif}
+	}
+	if len(files) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("reddit: none of the requested items exist")
+		}
+		return nil, lastErr
+	}
+	res := newResult(files, known)
+	res.Missing = missing
+	return res, nil
+}
+
+// rdPostID returns the post id of a Reddit link. Links that don't carry it
+// (/s/ share links, v.redd.it) are followed to the post page first.
+func rdPostID(ctx context.Context, rawURL string) (string, error) {
+	if m := rdCommentsRe.FindStringSubmatch(strings.ToLower(rawURL)); m != nil {
+		return m[1], nil
+	}
+	if m := rdShortRe.FindStringSubmatch(strings.ToLower(rawURL)); m != nil {
+		return m[1], nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", browserUA)
+	resp, err := rdHTTP().Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	// The redirect target is what matters; the page itself may be a login or
+	// bot wall.
+	if m := rdCommentsRe.FindStringSubmatch(strings.ToLower(resp.Request.URL.String())); m != nil {
+		return m[1], nil
+	}
+	return "", fmt.Errorf("reddit: could not resolve %s to a post (http %d)", rawURL, resp.StatusCode)
+}
+
+// rdFetchPost loads a post from /comments/<id>.json. raw_json=1 returns URLs
+// without HTML escaping (&amp;).
+func rdFetchPost(ctx context.Context, id string) (rdPost, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://www.reddit.com/comments/"+id+".json?raw_json=1", nil)
+	if err != nil {
+		return rdPost{}, err
+	}
+	req.Header.Set("User-Agent", browserUA)
+	req.Header.Set("Accept", "application/json")
+	if h := cookieHeader(loadCookies("reddit"), "reddit.com"); h != "" {
+		req.Header.Set("Cookie", h)
+	}
+	resp, err := rdHTTP().Do(req)
+	if err != nil {
+		return rdPost{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return rdPost{}, fmt.Errorf("reddit: post json http %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return rdPost{}, err
+	}
+	var listing rdListing
+	if err := json.Unmarshal(body, &listing); err != nil {
+		return rdPost{}, fmt.Errorf("reddit: decode: %w", err)
+	}
+	if len(listing) == 0 || len(listing[0].Data.Children) == 0 {
+		return rdPost{}, fmt.Errorf("reddit: post %s not found (removed or private)", id)
+	}
+	return listing[0].Data.Children[0].Data, nil
+}
+
+// collectRedditMedia lists a post's Reddit-hosted media in post order.
+func collectRedditMedia(p rdPost) []rdItem {
+	if p.GalleryData != nil && len(p.GalleryData.Items) > 0 {
+		var out []rdItem
+		for _, gi := range p.GalleryData.Items {
+			mm, ok := p.MediaMetadata[gi.MediaID]
+			if !ok {
+				out = append(out, rdItem{status: "missing"})
+				continue
+			}
+			it := rdItem{url: mm.S.U, ext: rdExt(mm.S.U, mm.M), width: mm.S.X, height: mm.S.Y, status: mm.Status}
+			if mm.E == "AnimatedImage" {
+				// The MP4 rendition is far smaller than the GIF and Telegram
+				// plays a silent clip as an animation anyway. Its URL still
+				// ends in .gif.
+				if mm.S.MP4 != "" {
+					it.url, it.ext, it.gif = mm.S.MP4, ".mp4", true
+				} else {
+					it.url, it.ext = mm.S.GIF, ".gif"
+				}
+			}
+			if it.url == "" && it.status == "" {
+				it.status = "unavailable"
+			}
+			out = append(out, it)
+		}
+		return out
+	}
+	if p.SecureMedia != nil && p.SecureMedia.RedditVideo != nil {
+		v := p.SecureMedia.RedditVideo
+		return []rdItem{{video: v, width: v.Width, height: v.Height}}
+	}
+	if u, err := url.Parse(p.URL); err == nil && (u.Host == "i.redd.it" || p.PostHint == "image") {
+		it := rdItem{url: p.URL, ext: rdExt(p.URL, "")}
+		if len(p.Preview.Images) > 0 {
+			it.width, it.height = p.Preview.Images[0].Source.Width, p.Preview.Images[0].Source.Height
+		}
+		return []rdItem{it}
+	}
+	return nil
+}
+
+// rdExt picks a file extension from the media URL's path, else its MIME type.
+func rdExt(mediaURL, mime string) string {
+	if u, err := url.Parse(mediaURL); err == nil {
+		switch ext := strings.ToLower(path.Ext(u.Path)); ext {
+		case ".jpg", ".jpeg", ".png", ".gif", ".webp", ".mp4":
+			return ext
+		}
+	}
+	switch strings.ToLower(mime) {
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".jpg"
+	}
+}
+
+// rdDownloadVideo fetches the best video track within maxH (0 = no cap) and the
+// audio track, then muxes them into base+".mp4". Posts without sound (and
+// GIF-like clips) have no audio track; the video track is then used as is.
+func rdDownloadVideo(ctx context.Context, v *rdVideo, base string, maxH int) (model.MediaFile, error) {
+	videoURL, audioURLs, height := v.FallbackURL, rdAudioCandidates(v.FallbackURL), v.Height
+	if v.DashURL != "" {
+		if tr, err := rdDashTracks(ctx, v.DashURL, maxH); err == nil && tr.video != "" {
+			videoURL, height = tr.video, tr.height
+			if tr.audio != "" {
+				audioURLs = []string{tr.audio}
+			} else {
+				audioURLs = nil
+			}
+		}
+	}
+	if videoURL == "" {
+		return model.MediaFile{}, fmt.Errorf("no video url")
+	}
+	if v.IsGIF || (v.HasAudio != nil && !*v.HasAudio) {
+		audioURLs = nil
+	}
+	width := v.Width
+	if height != v.Height && v.Height > 0 {
+		width = v.Width * height / v.Height
+	}
+	mf := model.MediaFile{SourceURL: videoURL, Width: width, Height: height, Duration: v.Duration, Animation: v.IsGIF}
+
+	dst := base + ".mp4"
+	videoPath := base + ".video.mp4"
+	if err := downloadTo(ctx, rdHTTP(), videoURL, videoPath, rdCDNHeaders); err != nil {
+		return mf, err
+	}
+	audioPath := base + ".audio.mp4"
+	haveAudio := false
+	for _, a := range audioURLs {
+		if downloadTo(ctx, rdHTTP(), a, audioPath, rdCDNHeaders) == nil {
+			haveAudio = true
+			break
+		}
+	}
+	if !haveAudio {
+		mf.Path = dst
+		return mf, os.Rename(videoPath, dst)
+	}
+	defer os.Remove(videoPath)
+	defer os.Remove(audioPath)
+	if err := media.MergeAV(ctx, videoPath, audioPath, dst); err != nil {
+		return mf, err
+	}
+	mf.Path = dst
+	return mf, nil
+}
+
+// rdAudioCandidates guesses the audio track next to a fallback video URL
+// (https://v.redd.it/<id>/DASH_720.mp4): its name changed over the years.
+func rdAudioCandidates(fallbackURL string) []string {
+	u, err := url.Parse(fallbackURL)
+	if err != nil || fallbackURL == "" {
+		return nil
+	}
+	u.RawQuery = ""
+	dir := strings.TrimSuffix(u.String(), path.Base(u.Path))
+	return []string{dir + "DASH_AUDIO_128.mp4", dir + "DASH_AUDIO_64.mp4", dir + "DASH_audio.mp4", dir + "audio"}
+}
+
+type rdMPD struct {
+	Periods []struct {
+		Sets []struct {
+			ContentType string `xml:"contentType,attr"`
+			MimeType    string `xml:"mimeType,attr"`
+			Reps        []struct {
+				MimeType  string `xml:"mimeType,attr"`
+				Bandwidth int    `xml:"bandwidth,attr"`
+				Height    int    `xml:"height,attr"`
+				BaseURL   string `xml:"BaseURL"`
+			} `xml:"Representation"`
+		} `xml:"AdaptationSet"`
+	} `xml:"Period"`
+}
+
+type rdTracks struct {
+	video, audio string
+	height       int
+}
+
+// rdDashTracks reads the post's DASH manifest and picks the tallest video
+// rendition within maxH (the shortest one when none fits) and the
+// highest-bitrate audio rendition.
+func rdDashTracks(ctx context.Context, dashURL string, maxH int) (rdTracks, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dashURL, nil)
+	if err != nil {
+		return rdTracks{}, err
+	}
+	req.Header.Set("User-Agent", browserUA)
+	resp, err := rdHTTP().Do(req)
+	if err != nil {
+		return rdTracks{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return rdTracks{}, fmt.Errorf("dash manifest http %d", resp.StatusCode)
+	}
+	var mpd rdMPD
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&mpd); err != nil {
+		return rdTracks{}, fmt.Errorf("dash manifest: %w", err)
+	}
+	base, err := url.Parse(dashURL)
+	if err != nil {
+		return rdTracks{}, err
+	}
+	resolve := func(ref string) string {
+		r, err := url.Parse(strings.TrimSpace(ref))
+		if err != nil {
+			return ""
+		}
+		return base.ResolveReference(r).String()
+	}
+
+	var tr rdTracks
+	bestFit, smallest, bestAudio := -1, -1, -1
+	var fitURL, smallURL string
+	for _, p := range mpd.Periods {
+		for _, set := range p.Sets {
+			for _, rep := range set.Reps {
+				kind := set.ContentType
+				if kind == "" {
+					mime := rep.MimeType
+					if mime == "" {
+						mime = set.MimeType
+					}
+					kind, _, _ = strings.Cut(mime, "/")
+				}
+				switch kind {
+				case "video":
+					if (maxH <= 0 || rep.Height <= maxH) && rep.Height > bestFit {
+						bestFit, fitURL = rep.Height, resolve(rep.BaseURL)
+					}
+					if smallest < 0 || rep.Height < smallest {
+						smallest, smallURL = rep.Height, resolve(rep.BaseURL)
+					}
+				case "audio":
+					if rep.Bandwidth > bestAudio {
+						bestAudio, tr.audio = rep.Bandwidth, resolve(rep.BaseURL)
+					}
+				}
+			}
+		}
+	}
+	if fitURL != "" {
+		tr.video, tr.height = fitURL, bestFit
+	} else {
+		tr.video, tr.height = smallURL, smallest
+	}
+	return tr, nil
+}
@@ -9,13 +9,30 @@ import (
 )
 
 type Registry struct {
-	Instagram  Strategy
-	YouTube    Strategy
-	TikTok     Strategy
-	Twitter    Strategy
-	Facebook   Strategy
-	Pinterest  Strategy
-	Default    Strategy
+	Instagram Strategy
+	YouTube   Strategy
+	TikTok    Strategy
+	Twitter   Strategy
+	Facebook  Strategy
+	Pinterest Strategy
+	Threads   Strategy
+	Reddit    Strategy
+	Default   Strategy
+
+	// Limits caps concurrent engine attempts per platform and per engine.
+	Limits ConcurrencyLimits
+
+	// Profiles lists account pages for bulk downloads, keyed by platform
+	// (see urlx.ProfileFromURL); a platform without one can't be bulk fetched.
+	Profiles map[string]ProfileLister
+}
+
+// ConcurrencyLimits bounds how many engine attempts run at once. Keys are
+// platform keys (see PlatformKey) and Engine.Name() values; a missing or zero
+// entry means no limit beyond the pipeline's global semaphore.
+type ConcurrencyLimits struct {
+	Platform map[string]int
+	Engine   map[string]int
 }
 
 func DefaultRegistry() Registry {
@@ -47,7 +64,8 @@ func DefaultRegistry() Registry {
 	return Registry{
 		// Instagram: curl_cffi graphql extractor first (fast + works on datacenter
 		// IPs), then the pure-Go extractor, Instaloader, and yt-dlp as fallbacks.
-		Instagram: instagramStrategy{fast: FastInstagramEngine{}, native: NativeInstagramEngine{}, insta: ig, yt: yt},
+		// Stories and highlights go to their own engine (cookie session only).
+		Instagram: instagramStrategy{stories: InstagramStoriesEngine{}, fast: FastInstagramEngine{}, native: NativeInstagramEngine{}, insta: ig, yt: yt},
 		// YouTube downloading is removed — it can't be fetched from a datacenter IP
 		// without a proxy/cookies, and no free workaround is reliable. main.go
 		// replies "not supported" for YouTube links; this no-engine strategy is a
@@ -55,12 +73,47 @@ func DefaultRegistry() Registry {
 		YouTube: noEngineStrategy{},
 		// gallery-dl is never used (it login-redirects on these platforms and was
 		// causing media download errors). Instaloader is Instagram-specific, so
-		// every other platform runs on yt-dlp alone — for both video and images.
-		TikTok:    ytOnlyStrategy{yt: yt},
-		Twitter:   ytOnlyStrategy{yt: yt},
-		Pinterest: ytOnlyStrategy{yt: yt},
-		Facebook:  ytOnlyStrategy{yt: yt},
+		// the other platforms rely on yt-dlp — for both video and images — behind
+		// their own native engines where there are any (TikTok's page parser and
+		// photo slideshows, which yt-dlp can't fetch).
+		TikTok: tiktokStrategy{
+			native: NativeTikTokEngine{},
+			// TIKTOK_SLIDESHOW_VIDEO=1 additionally renders photo posts into an MP4.
+			slides: TikTokSlideshowEngine{RenderVideo: os.Getenv("TIKTOK_SLIDESHOW_VIDEO") == "1"},
+			yt:     yt,
+		},
+		Twitter:   twitterStrategy{x: TwitterEngine{}, yt: yt},
+		Pinterest: pinterestStrategy{pin: PinterestEngine{}, yt: yt},
+		Facebook:  ytOnlyStrategy{yt: yt, types: []string{"video", "image"}},
 		Default:   ytOnlyStrategy{yt: yt},
+
+		// yt-dlp has no Threads extractor: the page parser is the only engine.
+		Threads: threadsStrategy{threads: ThreadsEngine{}},
+		// Reddit's .json endpoint gives galleries and the DASH tracks directly;
+		// yt-dlp covers posts linking to other hosts.
+		Reddit: redditStrategy{reddit: RedditEngine{}, yt: yt},
+
+		// A 2s Instagram photo and a 4-minute Facebook video shouldn't compete for
+		// the same slots, and instaloader bursts from one IP get us soft-banned.
+		Limits: ConcurrencyLimits{
+			Platform: map[string]int{
+				"instagram": 6,
+				"facebook":  3,
+			},
+			Engine: map[string]int{
+				"instaloader(images)": 2,
+				"instagram-fast":      4,
+				"instagram-native":    4,
+				"tiktok-native":       4,
+				"yt-dlp":              6,
+			},
+		},
+
+		Profiles: map[string]ProfileLister{
+			"instagram": InstagramProfileLister{},
+			"tiktok":    YtDlpProfileLister{Impersonate: yt.Impersonate},
+			"twitter":   TwitterProfileLister{},
+		},
 	}
 }
 
@@ -76,9 +129,16 @@ func maxHeight() string {
 }
 
 func (r Registry) StrategyFor(info *model.MediaInfo, url string) Strategy {
+	return r.strategyForPlatform(r.PlatformKey(info, url))
+}
+
+// PlatformKey is the platform a job is routed by: "instagram", "youtube",
+// "tiktok", "twitter", "facebook", "pinterest", "threads", "reddit", or
+// "default".
+func (r Registry) PlatformKey(info *model.MediaInfo, url string) string {
 	ul := strings.ToLower(url)
 	if strings.Contains(ul, "youtube.com") || strings.Contains(ul, "youtu.be") {
-		return r.YouTube
+		return "youtube"
 	}
 
 	plat := ""
@@ -90,20 +150,95 @@ func (r Registry) StrategyFor(info *model.MediaInfo, url string) Strategy {
 	plat = strings.ToLower(plat)
 
 	switch {
+	case strings.Contains(plat, "threads"):
+		return "threads"
+	case strings.Contains(plat, "reddit"):
+		return "reddit"
 	case strings.Contains(plat, "instagram"):
-		return r.Instagram
+		return "instagram"
 	case strings.Contains(plat, "youtube"):
-		return r.YouTube
+		return "youtube"
 	case strings.Contains(plat, "tiktok"):
-		return r.TikTok
+		return "tiktok"
 	case strings.Contains(plat, "twitter") || strings.Contains(plat, "x"):
-		return r.Twitter
+		return "twitter"
 	case strings.Contains(plat, "facebook"):
-		return r.Facebook
+		return "facebook"
 	case strings.Contains(plat, "pinterest"):
+		return "pinterest"
+	default:
+		return "default"
+	}
+}
+
+// EngineLimit returns the concurrency cap for an engine (0 = none), shared by
+// every platform running it.
+func (r Registry) EngineLimit(engine string) int {
+	return r.Limits.Engine[engine]
+}
+
+// PlatformLimit returns the concurrency cap for a platform key (0 = none).
+func (r Registry) PlatformLimit(platform string) int {
+	return r.Limits.Platform[platform]
+}
+
+// PlatformInfo describes one platform for user-facing help.
+type PlatformInfo struct {
+	Name         string   // platform key, as returned by urlx.PlatformFromURL
+	Label        string   // display name
+	ContentTypes []string // see ContentTyper
+	Cookies      bool     // a non-empty cookie file is loaded for it
+}
+
+// registryPlatforms lists the platforms in display order.
+var registryPlatforms = []struct{ name, label string }{
+	{"instagram", "Instagram"},
+	{"tiktok", "TikTok"},
+	{"twitter", "X / Twitter"},
+	{"facebook", "Facebook"},
+	{"pinterest", "Pinterest"},
+	{"threads", "Threads"},
+	{"reddit", "Reddit"},
+	{"youtube", "YouTube"},
+}
+
+func (r Registry) strategyForPlatform(name string) Strategy {
+	switch name {
+	case "instagram":
+		return r.Instagram
+	case "tiktok":
+		return r.TikTok
+	case "twitter":
+		return r.Twitter
+	case "facebook":
+		return r.Facebook
+	case "pinterest":
 		return r.Pinterest
+	case "threads":
+		return r.Threads
+	case "reddit":
+		return r.Reddit
+	case "youtube":
+		return r.YouTube
 	default:
 		return r.Default
 	}
 }
 
+// Platforms returns the enabled platforms (strategies with at least one
+// engine) in display order, so help text can't drift from what is wired up.
+func (r Registry) Platforms() []PlatformInfo {
+	var out []PlatformInfo
+	for _, p := range registryPlatforms {
+		strat := r.strategyForPlatform(p.name)
+		if strat == nil || len(strat.EnginesFor(nil)) == 0 {
+			continue
+		}
+		info := PlatformInfo{Name: p.name, Label: p.label, Cookies: CookiesPathForPlatform(p.name) != ""}
+		if ct, ok := strat.(ContentTyper); ok {
+			info.ContentTypes = ct.ContentTypes()
+		}
+		out = append(out, info)
+	}
+	return out
+}
@@ -0,0 +1,311 @@
+package platforms
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Scoreboard learns, per platform + media type + engine, how often an engine
+// succeeds and how fast, so adaptive strategies can rank engines by what is
+// actually working instead of a hand-tuned order. Success is an exponentially
+// weighted moving average; latency quantiles come from the most recent
+// successful runs. It is safe for concurrent use and persists as JSON.
+type Scoreboard struct {
+	// Alpha is the EWMA weight of each new outcome (default 0.1: roughly the
+	// last ~20 runs matter).
+	Alpha float64
+	// MinSamples is how many outcomes an engine needs before its score is
+	// trusted over the strategy's hand order (default 5).
+	MinSamples int
+	// Explore is the probability that a demoted engine is tried first anyway,
+	// so it can climb back after a fix (default 0.05; negative disables).
+	Explore float64
+
+	mu    sync.Mutex
+	stats map[string]*engineStats
+	dirty bool
+}
+
+// scoreLatencyWindow is how many recent successful latencies are kept for the
+// p50/p90 estimates.
+const scoreLatencyWindow = 32
+
+type engineStats struct {
+	Success   float64   `json:"success"`    // EWMA of outcomes, 1 = success
+	Samples   int       `json:"samples"`    // outcomes seen (capped, just a trust gate)
+	Latencies []float64 `json:"latencies"`  // seconds, most recent last
+	UpdatedAt time.Time `json:"updated_at"` // last observation
+}
+
+// EngineScore is a read-only view of one engine's stats.
+type EngineScore struct {
+	Key     string  `json:"key"`
+	Success float64 `json:"success"`
+	Samples int     `json:"samples"`
+	P50     float64 `json:"p50_seconds"`
+	P90     float64 `json:"p90_seconds"`
+	Score   float64 `json:"score"`
+}
+
+func scoreKey(platform, mediaType, engine string) string {
+	if mediaType == "" {
+		mediaType = "unknown"
+	}
+	return platform + "/" + mediaType + "/" + engine
+}
+
+func (s *Scoreboard) alpha() float64 {
+	if s.Alpha <= 0 || s.Alpha > 1 {
+		return 0.1
+	}
+	return s.Alpha
+}
+
+func (s *Scoreboard) minSamples() int {
+	if s.MinSamples <= 0 {
+		return 5
+	}
+	return s.MinSamples
+}
+
+func (s *Scoreboard) explore() float64 {
+	if s.Explore < 0 {
+		return 0
+	}
+	if s.Explore == 0 {
+		return 0.05
+	}
+	return s.Explore
+}
+
+// Observe records one engine run. Latency only counts for successes: a fast
+// failure says nothing about how long a real download takes.
+func (s *Scoreboard) Observe(platform, mediaType, engine string, ok bool, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stats == nil {
+		s.stats = make(map[string]*engineStats)
+	}
+	key := scoreKey(platform, mediaType, engine)
+	st, exists := s.stats[key]
+	outcome := 0.0
+	if ok {
+		outcome = 1
+	}
+	if !exists {
+		st = &engineStats{Success: outcome}
+		s.stats[key] = st
+	} else {
+		a := s.alpha()
+		st.Success = a*outcome + (1-a)*st.Success
+	}
+	if st.Samples < 1000 {
+		st.Samples++
+	}
+	if ok {
+		st.Latencies = append(st.Latencies, latency.Seconds())
+		if n := len(st.Latencies); n > scoreLatencyWindow {
+			st.Latencies = st.Latencies[n-scoreLatencyWindow:]
+		}
+	}
+	st.UpdatedAt = time.Now()
+	s.dirty = true
+}
+
+func quantile(xs []float64, q float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), xs...)
+	sort.Float64s(sorted)
+	return sorted[int(q*float64(len(sorted)-1)+0.5)]
+}
+
+// score is the expected "successes per second": success rate over p50
+// latency (+1s so near-instant engines don't dominate on noise alone).
+func (st *engineStats) score() float64 {
+	return st.Success / (quantile(st.Latencies, 0.5) + 1)
+}
+
+// Latency returns the engine's recent latency at quantile q (e.g. 0.9), and
+// false when there isn't enough data yet.
+func (s *Scoreboard) Latency(platform, mediaType, engine string, q float64) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.stats[scoreKey(platform, mediaType, engine)]
+	if !ok || len(st.Latencies) < s.minSamples() {
+		return 0, false
+	}
+	return time.Duration(quantile(st.Latencies, q) * float64(time.Second)), true
+}
+
+// Rank reorders engines best-first. Only engines with at least MinSamples
+// outcomes are reordered, among the positions they already occupy; engines
+// without enough data keep their hand-tuned slot. With probability Explore a
+// demoted engine (one now ranked below its hand position) is moved to the
+// front so it keeps getting the occasional chance to prove a fix.
+func (s *Scoreboard) Rank(platform, mediaType string, engines []Engine) []Engine {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := append([]Engine(nil), engines...)
+	var slots []int
+	scores := map[string]float64{}
+	for i, e := range engines {
+		st, ok := s.stats[scoreKey(platform, mediaType, e.Name())]
+		if !ok || st.Samples < s.minSamples() {
+			continue
+		}
+		slots = append(slots, i)
+		scores[e.Name()] = st.score()
+	}
+	if len(slots) < 2 {
+		return out
+	}
+	ranked := make([]Engine, 0, len(slots))
+	for _, i := range slots {
+		ranked = append(ranked, engines[i])
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		return scores[ranked[a].Name()] > scores[ranked[b].Name()]
+	})
+	for k, i := range slots {
+		out[i] = ranked[k]
+	}
+
+	if rand.Float64() < s.explore() {
+		var demoted []int
+		for i, e := range out {
+			if i > 0 && handIndex(engines, e) < i {
+				demoted = append(demoted, i)
+			}
+		}
+		if len(demoted) > 0 {
+			i := demoted[rand.IntN(len(demoted))]
+			e := out[i]
+			copy(out[1:i+1], out[:i])
+			out[0] = e
+		}
+	}
+	return out
+}
+
+func handIndex(engines []Engine, e Engine) int {
+	for i, h := range engines {
+		if h.Name() == e.Name() {
+			return i
+		}
+	}
+	return -1
+}
+
+// Snapshot returns every engine's score, sorted by key.
+func (s *Scoreboard) Snapshot() []EngineScore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]EngineScore, 0, len(s.stats))
+	for key, st := range s.stats {
+		out = append(out, EngineScore{
+			Key:     key,
+			Success: st.Success,
+			Samples: st.Samples,
+			P50:     quantile(st.Latencies, 0.5),
+			P90:     quantile(st.Latencies, 0.9),
+			Score:   st.score(),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// Load restores stats saved by Save. A missing file is not an error.
+func (s *Scoreboard) Load(path string) error {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	stats := map[string]*engineStats{}
+	if err := json.Unmarshal(raw, &stats); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.stats = stats
+	s.mu.Unlock()
+	return nil
+}
+
+// Save writes the stats to path atomically (temp file + rename), if anything
+// changed since the last save. A failed write leaves the stats dirty, so the
+// next Save retries it.
+func (s *Scoreboard) Save(path string) error {
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	raw, err := json.Marshal(s.stats)
+	// Cleared before the write so updates made during it mark it dirty again.
+	s.dirty = false
+	s.mu.Unlock()
+	if err == nil {
+		err = writeFileAtomic(path, raw)
+	}
+	if err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func writeFileAtomic(path string, raw []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// StartAutosave saves to path every interval until the returned stop func is
+// called (which saves one last time). Errors are returned via onErr, if set.
+func (s *Scoreboard) StartAutosave(path string, interval time.Duration, onErr func(error)) func() {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	save := func() {
+		if err := s.Save(path); err != nil && onErr != nil {
+			onErr(err)
+		}
+	}
+	go func() {
+		defer close(done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				save()
+				return
+			case <-t.C:
+				save()
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
@@ -2,6 +2,7 @@ package platforms
 
 import (
 	"strings"
+	"time"
 
 	"telegram_bot_downloader/internal/model"
 )
@@ -13,10 +14,15 @@ func (noEngineStrategy) EnginesFor(*model.MediaInfo) []Engine { return nil }
 
 func (noEngineStrategy) OptionsMatrix(string) []Options { return nil }
 
+func (noEngineStrategy) ContentTypes() []string { return nil }
+
 type ytOnlyStrategy struct {
-	yt Engine
+	yt    Engine
+	types []string // content types advertised in /help
 }
 
+func (s ytOnlyStrategy) ContentTypes() []string { return s.types }
+
 func (s ytOnlyStrategy) EnginesFor(_ *model.MediaInfo) []Engine {
 	return []Engine{s.yt}
 }
@@ -25,11 +31,98 @@ func (s ytOnlyStrategy) OptionsMatrix(url string) []Options {
 	return defaultRetryOptions(url)
 }
 
+type tiktokStrategy struct {
+	native Engine // pure-Go page parser — no-watermark MP4 without the yt-dlp startup
+	slides Engine // photo posts (image carousel + soundtrack)
+	yt     Engine
+}
+
+func (s tiktokStrategy) EnginesFor(info *model.MediaInfo) []Engine {
+	// /photo/ links are known slideshows: yt-dlp can't fetch them at all.
+	if info != nil && strings.EqualFold(info.Type, "slideshow") {
+		return []Engine{s.slides}
+	}
+	// Short links (vm.tiktok.com, /t/) don't reveal the type: try the video path
+	// (native first, yt-dlp when the page can't be parsed), then the slideshow
+	// engine. A photo post native recognises skips yt-dlp (see HandoffError).
+	return []Engine{s.native, s.yt, s.slides}
+}
+
+func (s tiktokStrategy) OptionsMatrix(url string) []Options {
+	return defaultRetryOptions(url)
+}
+
+func (tiktokStrategy) ContentTypes() []string { return []string{"video", "slideshow"} }
+
+type twitterStrategy struct {
+	x  Engine // syndication API — photos, videos and GIFs in one JSON request
+	yt Engine // yt-dlp — protected / age-restricted tweets, API changes
+}
+
+func (s twitterStrategy) EnginesFor(_ *model.MediaInfo) []Engine {
+	// yt-dlp alone ran a full video format cascade before failing on image
+	// tweets; the syndication engine knows each item's type up front.
+	return []Engine{s.x, s.yt}
+}
+
+func (s twitterStrategy) OptionsMatrix(url string) []Options {
+	return defaultRetryOptions(url)
+}
+
+func (twitterStrategy) ContentTypes() []string { return []string{"video", "image", "animation"} }
+
+type pinterestStrategy struct {
+	pin Engine // PinResource JSON — original-size images, carousels, story pins
+	yt  Engine // yt-dlp — fallback when the resource API changes or blocks
+}
+
+func (s pinterestStrategy) EnginesFor(_ *model.MediaInfo) []Engine {
+	return []Engine{s.pin, s.yt}
+}
+
+func (s pinterestStrategy) OptionsMatrix(url string) []Options {
+	return defaultRetryOptions(url)
+}
+
+func (pinterestStrategy) ContentTypes() []string { return []string{"image", "video", "carousel"} }
+
+type threadsStrategy struct {
+	threads Engine
+}
+
+func (s threadsStrategy) EnginesFor(_ *model.MediaInfo) []Engine {
+	return []Engine{s.threads}
+}
+
+func (s threadsStrategy) OptionsMatrix(url string) []Options {
+	return defaultRetryOptions(url)
+}
+
+func (threadsStrategy) ContentTypes() []string { return []string{"video", "image", "carousel"} }
+
+type redditStrategy struct {
+	reddit Engine // .json endpoint — galleries, images, GIFs, v.redd.it (DASH)
+	yt     Engine // yt-dlp — posts linking to other hosts
+}
+
+func (s redditStrategy) EnginesFor(_ *model.MediaInfo) []Engine {
+	return []Engine{s.reddit, s.yt}
+}
+
+func (s redditStrategy) OptionsMatrix(url string) []Options {
+	return defaultRetryOptions(url)
+}
+
+func (redditStrategy) ContentTypes() []string {
+	return []string{"video", "image", "animation", "carousel"}
+}
+
 type instagramStrategy struct {
-	fast   Engine // curl_cffi graphql extractor — fast AND works on datacenter IPs
-	native Engine // pure-Go graphql extractor — fast where TLS isn't fingerprinted
-	insta  Engine // instaloader fork — image/carousel fallback
-	yt     Engine // yt-dlp — reliable but slow fallback
+	stories Engine // stories / highlights (needs the cookie session)
+	fast    Engine // curl_cffi graphql extractor — fast AND works on datacenter IPs
+	native  Engine // pure-Go graphql extractor — fast where TLS isn't fingerprinted
+	insta   Engine // instaloader fork — image/carousel fallback
+	yt      Engine // yt-dlp — reliable but slow fallback
 }
 
 func (s instagramStrategy) EnginesFor(info *model.MediaInfo) []Engine {
@@ -39,6 +132,11 @@ func (s instagramStrategy) EnginesFor(info *model.MediaInfo) []Engine {
 	//   - native (pure Go): no subprocess, wins on residential/unflagged IPs; a
 	//     no-cost fallback since it never runs on the deploy (fast succeeds first).
 	// Both fall through on failure (e.g. Instagram rotating its doc_id).
+	// Stories and highlights have no shortcode: only the stories engine can
+	// fetch them, and its login-required error is the one worth reporting.
+	if info != nil && strings.EqualFold(info.Type, "story") {
+		return []Engine{s.stories}
+	}
 	if info != nil && strings.EqualFold(info.Type, "video") {
 		return []Engine{s.fast, s.native, s.yt}
 	}
@@ -51,6 +149,19 @@ func (s instagramStrategy) OptionsMatrix(url string) []Options {
 	return defaultRetryOptions(url)
 }
 
+// AdaptiveRanking: the order above was tuned by hand for Render's datacenter IP;
+// let the Scoreboard re-rank it when what actually works drifts (doc_id
+// rotations, 403 waves, a different host).
+func (instagramStrategy) AdaptiveRanking() bool { return true }
+
+// HedgeBudget: a hung instagram-fast attempt must not hold the fallback back by
+// its whole timeout. Used until the scoreboard has a p90 for the engine.
+func (instagramStrategy) HedgeBudget() time.Duration { return 4 * time.Second }
+
+func (instagramStrategy) ContentTypes() []string {
+	return []string{"video", "image", "carousel", "story"}
+}
+
 // defaultRetryOptions builds the attempt matrix. A single attempt: yt-dlp already
 // does its own internal retries, and a second app-level attempt mostly just
 // doubled the time for engines that ignore these options (Instaloader ran twice
@@ -60,4 +171,3 @@ func defaultRetryOptions(_ string) []Options {
 		{MaxHeight: maxHeight()},
 	}
 }
-
@@ -2,6 +2,10 @@ package platforms
 
 import (
 	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"telegram_bot_downloader/internal/model"
 )
@@ -16,7 +20,34 @@ type Options struct {
 	UserAgent   string
 	MaxHeight   string
 	MaxFilesize string // e.g. "50M"
-	MediaType   string // video, image, carousel, unknown — guides format selection
+	MediaType   string // video, image, carousel, slideshow, unknown — guides format selection
+
+	// Positions, if set, limits a multi-item post to these 1-based items (the
+	// ones a previous engine's partial result is missing). Only engines that
+	// implement PositionalEngine are given it.
+	Positions []int
+}
+
+// PositionalEngine is implemented by engines that honour Options.Positions
+// and report each file's post position in its MediaFile, so the pipeline can
+// ask them for just the items another engine's partial result is missing.
+type PositionalEngine interface {
+	SupportsPositions() bool
+}
+
+// wantPosition reports whether item pos (1-based) is requested by positions
+// (all items when none are given).
+func wantPosition(positions []int, pos int) bool {
+	return len(positions) == 0 || slices.Contains(positions, pos)
+}
+
+// joinInts formats positions as a list for yt-dlp / the helper scripts.
+func joinInts(ns []int, sep string) string {
+	parts := make([]string, len(ns))
+	for i, n := range ns {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, sep)
 }
 
 type Strategy interface {
@@ -24,3 +55,23 @@ type Strategy interface {
 	OptionsMatrix(url string) []Options
 }
 
+// ContentTyper is implemented by strategies that can say which content types
+// they download ("video", "image", "carousel", …); /help is generated from it.
+type ContentTyper interface {
+	ContentTypes() []string
+}
+
+// AdaptiveStrategy is implemented by strategies whose EnginesFor order is only
+// a prior: the pipeline may re-rank it from observed success and latency
+// (see Scoreboard).
+type AdaptiveStrategy interface {
+	AdaptiveRanking() bool
+}
+
+// HedgingStrategy is implemented by latency-critical strategies that opt in to
+// hedged attempts: when an engine hasn't finished within its latency budget
+// (its observed p90, else HedgeBudget), the next engine starts in parallel and
+// the first complete result wins. A zero budget disables hedging.
+type HedgingStrategy interface {
+	HedgeBudget() time.Duration
+}
@@ -0,0 +1,149 @@
+package platforms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"telegram_bot_downloader/internal/model"
+)
+
+// ThreadsEngine downloads Threads (threads.net / threads.com) posts. Threads is
+// built on Instagram's backend: the post page embeds the post as JSON in its
+// data-sjs <script> blobs, with the same image_versions2 / video_versions /
+// carousel_media shapes collectIGMedia parses. Text-only posts fail with
+// ErrNoMedia.
+type ThreadsEngine struct{}
+
+func (ThreadsEngine) Name() string { return "threads" }
+
+func (ThreadsEngine) SupportsPositions() bool { return true }
+
+var (
+	threadsCodeRe = regexp.MustCompile(`threads\.(?:net|com)/(?:@[^/]+/post|t)/([A-Za-z0-9_-]+)`)
+	sjsScriptRe   = regexp.MustCompile(`(?s)<script type="application/json"[^>]*>(.*?)</script>`)
+)
+
+func (e ThreadsEngine) Download(ctx context.Context, rawURL string, jobDir string, opts Options) (*model.DownloadResult, error) {
+	m := threadsCodeRe.FindStringSubmatch(rawURL)
+	if m == nil {
+		return nil, fmt.Errorf("threads: could not extract post code")
+	}
+	code := m[1]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", browserUA)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("Sec-Fetch-Mode", "navigate")
+	resp, err := igHTTP().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("threads: page http %d", resp.StatusCode)
+	}
+	page, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	item, ok := findThreadsPost(page, code)
+	if !ok {
+		return nil, fmt.Errorf("threads: post %s not found in page (login wall or layout change)", code)
+	}
+	media := collectIGMedia(item)
+	if len(media) == 0 {
+		return nil, fmt.Errorf("threads: post %s: %w", code, ErrNoMedia)
+	}
+
+	var files []string
+	var missing []int
+	var lastErr error
+	known := map[string]model.MediaFile{}
+	for i, m := range media {
+		if !wantPosition(opts.Positions, i+1) {
+			continue
+		}
+		ext := ".jpg"
+		if m.isVideo {
+			ext = ".mp4"
+		}
+		dst := filepath.Join(jobDir, fmt.Sprintf("%s_%02d%s", code, i, ext))
+		if err := downloadTo(ctx, igHTTP(), m.url, dst, nil); err != nil {
+			missing = append(missing, i+1)
+			lastErr = fmt.Errorf("threads: download item %d: %w", i+1, err)
+			continue
+		}
+		files = append(files, dst)
+		known[dst] = model.MediaFile{SourceURL: m.url, Width: m.width, Height: m.height, Duration: m.duration, Position: i + 1}
+	}
+	if len(files) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("threads: none of the requested items exist")
+		}
+		return nil, lastErr
+	}
+	res := newResult(files, known)
+	res.Missing = missing
+	return res, nil
+}
+
+// findThreadsPost searches the page's JSON blobs for the post object with the
+// given code (replies and quoted posts on the page have their own codes).
+func findThreadsPost(page []byte, code string) (igItem, bool) {
+	for _, m := range sjsScriptRe.FindAllSubmatch(page, -1) {
+		blob := m[1]
+		if !strings.Contains(string(blob), code) {
+			continue
+		}
+		var v any
+		if json.Unmarshal(blob, &v) != nil {
+			continue
+		}
+		if raw, ok := findIGObject(v, code); ok {
+			var item igItem
+			if json.Unmarshal(raw, &item) == nil {
+				return item, true
+			}
+		}
+	}
+	return igItem{}, false
+}
+
+// findIGObject walks decoded JSON for an object with "code" == code that
+// carries Instagram-style media fields, and returns it re-encoded.
+func findIGObject(v any, code string) (json.RawMessage, bool) {
+	switch t := v.(type) {
+	case map[string]any:
+		if c, _ := t["code"].(string); c == code {
+			_, img := t["image_versions2"]
+			_, vid := t["video_versions"]
+			_, car := t["carousel_media"]
+			if img || vid || car {
+				raw, err := json.Marshal(t)
+				return raw, err == nil
+			}
+		}
+		for _, child := range t {
+			if raw, ok := findIGObject(child, code); ok {
+				return raw, true
+			}
+		}
+	case []any:
+		for _, child := range t {
+			if raw, ok := findIGObject(child, code); ok {
+				return raw, true
+			}
+		}
+	}
+	return nil, false
+}
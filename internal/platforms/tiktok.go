@@ -0,0 +1,145 @@
+package platforms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TikTok pages embed the whole post as JSON in a
+// <script id="__UNIVERSAL_DATA_FOR_REHYDRATION__"> tag. One page fetch gives the
+// media URLs AND the tt_chain_token cookie the CDN checks, so the downloads must
+// go over the same client (shared cookie jar) with a tiktok.com Referer.
+
+var (
+	ttOnce   sync.Once
+	ttClient *http.Client
+
+	// The Referer + page cookies are what the CDN checks; without them it
+	// answers 403.
+	ttCDNHeaders = map[string]string{"Referer": "https://www.tiktok.com/"}
+
+	ttRehydrationRe = regexp.MustCompile(`(?s)<script[^>]+id="__UNIVERSAL_DATA_FOR_REHYDRATION__"[^>]*>(.*?)</script>`)
+)
+
+// ttHTTP returns the shared keep-alive TikTok client. The cookie jar carries the
+// page's tt_chain_token over to the CDN requests; redirects are followed so
+// vm.tiktok.com / tiktok.com/t/ short links resolve to the post page.
+func ttHTTP() *http.Client {
+	ttOnce.Do(func() {
+		ttClient = NewKeepAliveClient(60*time.Second, true)
+	})
+	return ttClient
+}
+
+// ttImage is one slide of a photo post; URLList holds the same image from
+// several CDN hosts / encodings.
+type ttImage struct {
+	ImageURL struct {
+		URLList []string `json:"urlList"`
+	} `json:"imageURL"`
+	ImageWidth  int `json:"imageWidth"`
+	ImageHeight int `json:"imageHeight"`
+}
+
+// ttBitrate is one no-watermark rendition of a video (bitrateInfo); TikTok
+// capitalises these keys.
+type ttBitrate struct {
+	Bitrate   int    `json:"Bitrate"`
+	CodecType string `json:"CodecType"` // "h264", "h265_hvc1", "bytevc1", …
+	PlayAddr  struct {
+		URLList []string `json:"UrlList"`
+		Width   int      `json:"Width"`
+		Height  int      `json:"Height"`
+	} `json:"PlayAddr"`
+}
+
+type ttItem struct {
+	ID    string `json:"id"`
+	Desc  string `json:"desc"`
+	Video struct {
+		Duration int `json:"duration"`
+		Width    int `json:"width"`
+		Height   int `json:"height"`
+		// PlayAddr is the default no-watermark rendition (downloadAddr is the
+		// watermarked one); BitrateInfo lists every rendition.
+		PlayAddr    string      `json:"playAddr"`
+		BitrateInfo []ttBitrate `json:"bitrateInfo"`
+	} `json:"video"`
+	Music struct {
+		PlayURL  string `json:"playUrl"`
+		Title    string `json:"title"`
+		Duration int    `json:"duration"`
+	} `json:"music"`
+	ImagePost struct {
+		Images []ttImage `json:"images"`
+	} `json:"imagePost"`
+}
+
+type ttRehydration struct {
+	DefaultScope struct {
+		VideoDetail struct {
+			StatusCode int `json:"statusCode"`
+			ItemInfo   struct {
+				ItemStruct ttItem `json:"itemStruct"`
+			} `json:"itemInfo"`
+		} `json:"webapp.video-detail"`
+	} `json:"__DEFAULT_SCOPE__"`
+}
+
+// ttFetchItem loads the post page and returns its itemStruct.
+func ttFetchItem(ctx context.Context, rawURL string) (*ttItem, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", browserUA)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	resp, err := ttHTTP().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tiktok: page http %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return nil, err
+	}
+	m := ttRehydrationRe.FindSubmatch(body)
+	if m == nil {
+		return nil, fmt.Errorf("tiktok: no rehydration data in page")
+	}
+	var data ttRehydration
+	if err := json.Unmarshal(m[1], &data); err != nil {
+		return nil, fmt.Errorf("tiktok: decode: %w", err)
+	}
+	detail := data.DefaultScope.VideoDetail
+	item := detail.ItemInfo.ItemStruct
+	if item.ID == "" {
+		return nil, fmt.Errorf("tiktok: empty item (status %d; removed or private)", detail.StatusCode)
+	}
+	return &item, nil
+}
+
+// ttPickImageURL prefers a JPEG rendition (Telegram's sendPhoto handles it
+// everywhere); otherwise the first URL.
+func ttPickImageURL(img ttImage) string {
+	urls := img.ImageURL.URLList
+	for _, u := range urls {
+		if strings.Contains(strings.ToLower(u), "jpeg") || strings.Contains(strings.ToLower(u), ".jpg") {
+			return u
+		}
+	}
+	if len(urls) > 0 {
+		return urls[0]
+	}
+	return ""
+}
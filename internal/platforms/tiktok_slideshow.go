@@ -0,0 +1,99 @@
+package platforms
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"telegram_bot_downloader/internal/media"
+	"telegram_bot_downloader/internal/model"
+)
+
+// TikTokSlideshowEngine downloads TikTok photo posts (/photo/ image carousels
+// with a soundtrack): every slide plus the music track. yt-dlp only knows the
+// video path and fails on these ("Unsupported URL" / no formats).
+//
+// Files come back in send order: <id>_00.jpg … <id>_NN.jpg, <id>_audio.mp3,
+// then the optional <id>_slideshow.mp4.
+type TikTokSlideshowEngine struct {
+	// RenderVideo also renders an MP4 of the slideshow (slides + soundtrack)
+	// with ffmpeg. TikTok doesn't report per-slide timing, so the post's length
+	// is split evenly over the slides. Off by default: it costs an encode.
+	RenderVideo bool
+}
+
+func (TikTokSlideshowEngine) Name() string { return "tiktok-slideshow" }
+
+// ttDefaultSlideSeconds is TikTok's auto-advance interval, used when the post
+// doesn't report a total duration to divide between its slides.
+const ttDefaultSlideSeconds = 3.0
+
+func (e TikTokSlideshowEngine) Download(ctx context.Context, url string, jobDir string, opts Options) (*model.DownloadResult, error) {
+	item, err := ttFetchItem(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	images := item.ImagePost.Images
+	if len(images) == 0 {
+		return nil, fmt.Errorf("tiktok-slideshow: not a photo post")
+	}
+
+	var slides []string
+	known := map[string]model.MediaFile{}
+	for i, img := range images {
+		u := ttPickImageURL(img)
+		if u == "" {
+			return nil, fmt.Errorf("tiktok-slideshow: slide %d has no url", i)
+		}
+		dst := filepath.Join(jobDir, fmt.Sprintf("%s_%02d.jpg", item.ID, i))
+		if err := downloadTo(ctx, ttHTTP(), u, dst, ttCDNHeaders); err != nil {
+			return nil, fmt.Errorf("tiktok-slideshow: download slide %d: %w", i, err)
+		}
+		slides = append(slides, dst)
+		known[dst] = model.MediaFile{SourceURL: u, Width: img.ImageWidth, Height: img.ImageHeight}
+	}
+
+	// The soundtrack is best-effort: a slideshow without its audio is still the
+	// post the user asked for.
+	files := append([]string(nil), slides...)
+	var audio string
+	if item.Music.PlayURL != "" {
+		dst := filepath.Join(jobDir, item.ID+"_audio.mp3")
+		if err := downloadTo(ctx, ttHTTP(), item.Music.PlayURL, dst, ttCDNHeaders); err == nil {
+			audio = dst
+			files = append(files, dst)
+			known[dst] = model.MediaFile{SourceURL: item.Music.PlayURL, Duration: float64(item.Music.Duration)}
+		}
+	}
+
+	if e.RenderVideo {
+		dst := filepath.Join(jobDir, item.ID+"_slideshow.mp4")
+		// A failed render just leaves the album + audio.
+		durations := ttSlideDurations(item)
+		if err := media.RenderSlideshow(ctx, slides, durations, audio, dst); err == nil {
+			var total float64
+			for _, d := range durations {
+				total += d
+			}
+			files = append(files, dst)
+			known[dst] = model.MediaFile{Width: 1080, Height: 1920, Duration: total}
+		}
+	}
+	return newResult(files, known), nil
+}
+
+// ttSlideDurations spreads the post's duration evenly over its slides. The
+// item JSON has no per-slide timing, so this approximates the app's
+// auto-advance rather than reproducing it.
+func ttSlideDurations(item *ttItem) []float64 {
+	n := len(item.ImagePost.Images)
+	per := ttDefaultSlideSeconds
+	if item.Video.Duration > 0 {
+		per = float64(item.Video.Duration) / float64(n)
+	}
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = per
+	}
+	return out
+}
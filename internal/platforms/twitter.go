@@ -0,0 +1,294 @@
+package platforms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram_bot_downloader/internal/model"
+)
+
+// TwitterEngine downloads a tweet's media from the public syndication API
+// (cdn.syndication.twimg.com/tweet-result, what embedded tweets use): one JSON
+// request, no login, no guest token. Photos are fetched at name=orig, videos as
+// the highest-bitrate MP4 within the height cap, and "GIFs" as their silent MP4
+// (marked MediaFile.Animation so they're sent as animations), all in tweet
+// order. A tweet without media, card or quoted media fails with ErrNoMedia;
+// cards, quoted media and protected, age-restricted or deleted tweets fail so
+// the pipeline falls back to yt-dlp.
+type TwitterEngine struct{}
+
+func (TwitterEngine) Name() string { return "x-syndication" }
+
+func (TwitterEngine) SupportsPositions() bool { return true }
+
+var (
+	twOnce   sync.Once
+	twClient *http.Client
+
+	twStatusRe  = regexp.MustCompile(`(?:twitter|x)\.com/(?:[^/]+|i/web)/status(?:es)?/(\d+)`)
+	twVariantRe = regexp.MustCompile(`/(\d+)x(\d+)/`)
+)
+
+// twHTTP returns the shared keep-alive client for the syndication API and the
+// pbs/video.twimg.com CDNs.
+func twHTTP() *http.Client {
+	twOnce.Do(func() {
+		twClient = NewKeepAliveClient(60*time.Second, false)
+	})
+	return twClient
+}
+
+type twVariant struct {
+	Bitrate     int    `json:"bitrate"`
+	ContentType string `json:"content_type"`
+	URL         string `json:"url"`
+}
+
+type twMedia struct {
+	Type          string `json:"type"` // "photo", "video" or "animated_gif"
+	MediaURLHTTPS string `json:"media_url_https"`
+	OriginalInfo  struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"original_info"`
+	VideoInfo struct {
+		DurationMillis int         `json:"duration_millis"`
+		Variants       []twVariant `json:"variants"`
+	} `json:"video_info"`
+}
+
+type twTweetResult struct {
+	Typename     string    `json:"__typename"`
+	IDStr        string    `json:"id_str"`
+	MediaDetails []twMedia `json:"mediaDetails"`
+	Card         *struct{} `json:"card"` // link/player card (e.g. an embedded video)
+	QuotedTweet  *struct {
+		MediaDetails []twMedia `json:"mediaDetails"`
+	} `json:"quoted_tweet"`
+}
+
+func (e TwitterEngine) Download(ctx context.Context, rawURL string, jobDir string, opts Options) (*model.DownloadResult, error) {
+	m := twStatusRe.FindStringSubmatch(rawURL)
+	if m == nil {
+		return nil, fmt.Errorf("x-syndication: could not extract status id")
+	}
+	id := m[1]
+	tweet, err := twFetchTweet(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(tweet.MediaDetails) == 0 {
+		// Card players and quoted media aren't in mediaDetails; yt-dlp may
+		// still get them.
+		if tweet.Card != nil || (tweet.QuotedTweet != nil && len(tweet.QuotedTweet.MediaDetails) > 0) {
+			return nil, fmt.Errorf("x-syndication: tweet %s: media is in a card or quoted tweet", id)
+		}
+		return nil, fmt.Errorf("x-syndication: tweet %s: %w", id, ErrNoMedia)
+	}
+	maxH, _ := strconv.Atoi(strings.TrimSpace(opts.MaxHeight))
+
+	var files []string
+	var missing []int
+	var lastErr error
+	known := map[string]model.MediaFile{}
+	for i, md := range tweet.MediaDetails {
+		if !wantPosition(opts.Positions, i+1) {
+			continue
+		}
+		mf := model.MediaFile{Width: md.OriginalInfo.Width, Height: md.OriginalInfo.Height, Position: i + 1}
+		var ext string
+		switch md.Type {
+		case "photo":
+			mf.SourceURL, ext = twOrigPhotoURL(md.MediaURLHTTPS)
+		default: // video, animated_gif
+			var w, h int
+			mf.SourceURL, w, h = twPickVariant(md.VideoInfo.Variants, maxH)
+			if w > 0 && h > 0 {
+				mf.Width, mf.Height = w, h
+			}
+			mf.Duration = float64(md.VideoInfo.DurationMillis) / 1000
+			mf.Animation = md.Type == "animated_gif"
+			ext = ".mp4"
+		}
+		if mf.SourceURL == "" {
+			missing = append(missing, i+1)
+			lastErr = fmt.Errorf("x-syndication: item %d (%s) has no url", i+1, md.Type)
+			continue
+		}
+		dst := filepath.Join(jobDir, fmt.Sprintf("%s_%02d%s", id, i, ext))
+		if err := downloadTo(ctx, twHTTP(), mf.SourceURL, dst, nil); err != nil {
+			missing = append(missing, i+1)
+			lastErr = fmt.Errorf("x-syndication: download item %d: %w", i+1, err)
+			continue
+		}
+		files = append(files, dst)
+		known[dst] = mf
+	}
+	if len(files) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("x-syndication: none of the requested items exist")
+		}
+		return nil, lastErr
+	}
+	res := newResult(files, known)
+	res.Missing = missing
+	return res, nil
+}
+
+// twFetchTweet calls tweet-result for a status id.
+func twFetchTweet(ctx context.Context, id string) (*twTweetResult, error) {
+	q := url.Values{"id": {id}, "lang": {"en"}, "token": {twSyndicationToken(id)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://cdn.syndication.twimg.com/tweet-result?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", browserUA)
+	resp, err := twHTTP().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("x-syndication: http %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	var t twTweetResult
+	if err := json.Unmarshal(body, &t); err != nil {
+		return nil, fmt.Errorf("x-syndication: decode: %w", err)
+	}
+	// Protected / age-restricted tweets come back as {} or a TweetTombstone.
+	if t.Typename != "Tweet" || t.IDStr == "" {
+		return nil, fmt.Errorf("x-syndication: tweet %s unavailable (%q)", id, t.Typename)
+	}
+	return &t, nil
+}
+
+// twSyndicationToken computes the token the embed widget sends:
+// ((Number(id) / 1e15) * Math.PI).toString(36).replace(/(0+|\.)/g, "").
+func twSyndicationToken(id string) string {
+	n, err := strconv.ParseFloat(id, 64)
+	if err != nil {
+		return ""
+	}
+	s := jsFloatToRadix(n/1e15*math.Pi, 36)
+	return strings.NewReplacer("0", "", ".", "").Replace(s)
+}
+
+// jsFloatToRadix formats a non-negative float like JavaScript's
+// Number.prototype.toString(radix): the shortest digit string that reads back
+// as the same double (V8's DoubleToRadixCString).
+func jsFloatToRadix(v float64, radix int) string {
+	const chars = "0123456789abcdefghijklmnopqrstuvwxyz"
+	integer := math.Floor(v)
+	fraction := v - integer
+	delta := math.Max(0.5*(math.Nextafter(v, math.Inf(1))-v), math.SmallestNonzeroFloat64)
+
+	var frac []byte
+	if fraction >= delta {
+		for {
+			fraction *= float64(radix)
+			delta *= float64(radix)
+			digit := int(fraction)
+			frac = append(frac, chars[digit])
+			fraction -= float64(digit)
+			if fraction > 0.5 || (fraction == 0.5 && digit&1 == 1) {
+				if fraction+delta > 1 {
+					// Round up, carrying through the digits written so far.
+					for {
+						if len(frac) == 0 {
+							integer++
+							break
+						}
+						last := strings.IndexByte(chars, frac[len(frac)-1])
+						frac = frac[:len(frac)-1]
+						if last+1 < radix {
+							frac = append(frac, chars[last+1])
+							break
+						}
+					}
+					break
+				}
+			}
+			if fraction < delta {
+				break
+			}
+		}
+	}
+	out := strconv.FormatInt(int64(integer), radix)
+	if len(frac) > 0 {
+		out += "." + string(frac)
+	}
+	return out
+}
+
+// twOrigPhotoURL turns a pbs.twimg.com media URL into its original-size form
+// (…/media/<id>?format=jpg&name=orig) and returns the file extension.
+func twOrigPhotoURL(mediaURL string) (string, string) {
+	u, err := url.Parse(mediaURL)
+	if err != nil || mediaURL == "" {
+		return "", ""
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	if ext == "" {
+		ext = ".jpg"
+	}
+	u.Path = strings.TrimSuffix(u.Path, path.Ext(u.Path))
+	u.RawQuery = url.Values{"format": {strings.TrimPrefix(ext, ".")}, "name": {"orig"}}.Encode()
+	return u.String(), ext
+}
+
+// twPickVariant picks the highest-bitrate MP4 whose short side is within maxH
+// (0 = no cap), or the lowest-bitrate MP4 when none fits. Dimensions come from
+// the variant URL (…/vid/avc1/1280x720/…); GIF variants carry none and always
+// fit.
+func twPickVariant(variants []twVariant, maxH int) (src string, width, height int) {
+	best, lowest := -1, -1
+	for i, v := range variants {
+		if v.ContentType != "video/mp4" || v.URL == "" {
+			continue
+		}
+		if lowest < 0 || v.Bitrate < variants[lowest].Bitrate {
+			lowest = i
+		}
+		w, h := twVariantSize(v.URL)
+		if maxH > 0 && w > 0 && min(w, h) > maxH {
+			continue
+		}
+		if best < 0 || v.Bitrate > variants[best].Bitrate {
+			best = i
+		}
+	}
+	if best < 0 {
+		best = lowest
+	}
+	if best < 0 {
+		return "", 0, 0
+	}
+	w, h := twVariantSize(variants[best].URL)
+	return variants[best].URL, w, h
+}
+
+func twVariantSize(variantURL string) (int, int) {
+	m := twVariantRe.FindStringSubmatch(variantURL)
+	if m == nil {
+		return 0, 0
+	}
+	w, _ := strconv.Atoi(m[1])
+	h, _ := strconv.Atoi(m[2])
+	return w, h
+}
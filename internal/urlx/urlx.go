@@ -28,8 +28,12 @@ func PlatformFromURL(u string) string {
 	switch {
 	case strings.Contains(l, "youtube.com") || strings.Contains(l, "youtu.be"):
 		return "youtube"
+	case strings.Contains(l, "threads.net") || strings.Contains(l, "threads.com"):
+		return "threads"
 	case strings.Contains(l, "instagram.com"):
 		return "instagram"
+	case strings.Contains(l, "reddit.com") || strings.Contains(l, "redd.it"):
+		return "reddit"
 	case strings.Contains(l, "tiktok.com"):
 		return "tiktok"
 	case strings.Contains(l, "twitter.com") || strings.Contains(l, "x.com"):
@@ -43,3 +47,63 @@ func PlatformFromURL(u string) string {
 	}
 }
 
+// Profile is an account page a user asked to bulk download.
+type Profile struct {
+	Platform   string // "instagram", "tiktok" or "twitter"
+	Handle     string // username, without "@"
+	VideosOnly bool   // instagram.com/<user>/reels/
+}
+
+// igReservedPaths are first path segments of instagram.com that aren't
+// usernames.
+var igReservedPaths = map[string]bool{
+	"p": true, "reel": true, "reels": true, "tv": true, "stories": true,
+	"explore": true, "accounts": true, "direct": true, "about": true,
+	"developer": true, "legal": true, "web": true, "share": true, "s": true,
+}
+
+// xReservedPaths are first path segments of x.com / twitter.com that aren't
+// usernames.
+var xReservedPaths = map[string]bool{
+	"i": true, "home": true, "explore": true, "search": true, "settings": true,
+	"messages": true, "notifications": true, "hashtag": true, "intent": true,
+}
+
+// ProfileFromURL recognises profile pages: instagram.com/<user>/ (and
+// /<user>/reels/), tiktok.com/@<user> and x.com/<user>/media (twitter.com
+// too). Post, story and share links report false.
+func ProfileFromURL(raw string) (Profile, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return Profile{}, false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	host = strings.TrimPrefix(host, "m.")
+	var segs []string
+	for _, s := range strings.Split(u.Path, "/") {
+		if s != "" {
+			segs = append(segs, s)
+		}
+	}
+	switch host {
+	case "instagram.com":
+		if len(segs) == 0 || igReservedPaths[strings.ToLower(segs[0])] {
+			return Profile{}, false
+		}
+		switch {
+		case len(segs) == 1:
+			return Profile{Platform: "instagram", Handle: segs[0]}, true
+		case len(segs) == 2 && strings.EqualFold(segs[1], "reels"):
+			return Profile{Platform: "instagram", Handle: segs[0], VideosOnly: true}, true
+		}
+	case "tiktok.com":
+		if len(segs) == 1 && strings.HasPrefix(segs[0], "@") && len(segs[0]) > 1 {
+			return Profile{Platform: "tiktok", Handle: segs[0][1:]}, true
+		}
+	case "x.com", "twitter.com", "mobile.twitter.com":
+		if len(segs) == 2 && strings.EqualFold(segs[1], "media") && !xReservedPaths[strings.ToLower(segs[0])] {
+			return Profile{Platform: "twitter", Handle: segs[0]}, true
+		}
+	}
+	return Profile{}, false
+}
@@ -0,0 +1,192 @@
+package worker
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// BreakerState is a circuit breaker's position.
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // calls flow normally
+	BreakerOpen     BreakerState = "open"      // calls are skipped until the cooldown ends
+	BreakerHalfOpen BreakerState = "half-open" // one probe call is testing recovery
+)
+
+// breakerWindow is how many recent outcomes are kept per key for the rolling
+// success rate.
+const breakerWindow = 20
+
+// BreakerSet is a set of circuit breakers keyed by an arbitrary string (the
+// pipeline uses "platform/engine"). A breaker trips after Threshold consecutive
+// failures, skips its key for Cooldown, then lets a single probe through: a
+// success closes it, a failure re-opens it for another cooldown.
+type BreakerSet struct {
+	Threshold int           // consecutive failures to trip (default 5)
+	Cooldown  time.Duration // how long an open breaker skips its key (default 5m)
+
+	// OnChange, if set, is called (outside the lock) on every state transition.
+	OnChange func(key string, from, to BreakerState)
+
+	mu sync.Mutex
+	m  map[string]*breaker
+}
+
+type breaker struct {
+	state    BreakerState
+	failures int // consecutive
+	openedAt time.Time
+	probing  bool // half-open probe in flight
+	lastErr  string
+
+	window [breakerWindow]bool // ring of recent outcomes (true = success)
+	n      int                 // outcomes recorded so far (caps at breakerWindow)
+	next   int
+}
+
+// BreakerStatus is a point-in-time view of one breaker.
+type BreakerStatus struct {
+	Key                 string       `json:"key"`
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	RecentSuccesses     int          `json:"recent_successes"`
+	RecentTotal         int          `json:"recent_total"`
+	LastError           string       `json:"last_error,omitempty"`
+	RetryAt             time.Time    `json:"retry_at,omitzero"`
+}
+
+func (s *BreakerSet) threshold() int {
+	if s.Threshold <= 0 {
+		return 5
+	}
+	return s.Threshold
+}
+
+func (s *BreakerSet) cooldown() time.Duration {
+	if s.Cooldown <= 0 {
+		return 5 * time.Minute
+	}
+	return s.Cooldown
+}
+
+func (s *BreakerSet) get(key string) *breaker {
+	if s.m == nil {
+		s.m = make(map[string]*breaker)
+	}
+	b, ok := s.m[key]
+	if !ok {
+		b = &breaker{state: BreakerClosed}
+		s.m[key] = b
+	}
+	return b
+}
+
+func (s *BreakerSet) notify(key string, from, to BreakerState) {
+	if from != to && s.OnChange != nil {
+		s.OnChange(key, from, to)
+	}
+}
+
+// Ready reports, without claiming anything, whether Allow would currently let
+// a call for key through.
+func (s *BreakerSet) Ready(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.get(key)
+	switch b.state {
+	case BreakerOpen:
+		return time.Since(b.openedAt) >= s.cooldown()
+	case BreakerHalfOpen:
+		return !b.probing
+	}
+	return true
+}
+
+// Allow reports whether a call for key may run now. When an open breaker's
+// cooldown has passed, exactly one caller is let through as the probe; the
+// caller must then report its outcome with Record (or Release if it never ran).
+func (s *BreakerSet) Allow(key string) bool {
+	s.mu.Lock()
+	b := s.get(key)
+	from := b.state
+	allowed := true
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < s.cooldown() {
+			allowed = false
+			break
+		}
+		b.state, b.probing = BreakerHalfOpen, true
+	case BreakerHalfOpen:
+		if b.probing {
+			allowed = false
+			break
+		}
+		b.probing = true
+	}
+	to := b.state
+	s.mu.Unlock()
+	s.notify(key, from, to)
+	return allowed
+}
+
+// Record reports the outcome of an allowed call (err == nil is a success).
+func (s *BreakerSet) Record(key string, err error) {
+	s.mu.Lock()
+	b := s.get(key)
+	from := b.state
+	b.probing = false
+	b.window[b.next] = err == nil
+	b.next = (b.next + 1) % breakerWindow
+	b.n = min(b.n+1, breakerWindow)
+	if err == nil {
+		b.failures = 0
+		b.state = BreakerClosed
+	} else {
+		b.failures++
+		b.lastErr = err.Error()
+		if b.state == BreakerHalfOpen || b.failures >= s.threshold() {
+			b.state, b.openedAt = BreakerOpen, time.Now()
+		}
+	}
+	to := b.state
+	s.mu.Unlock()
+	s.notify(key, from, to)
+}
+
+// Release gives back a probe slot taken by Allow when the call never produced
+// an outcome (e.g. the job was cancelled), so the next caller can probe.
+func (s *BreakerSet) Release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.get(key).probing = false
+}
+
+// Snapshot returns every breaker's status, sorted by key.
+func (s *BreakerSet) Snapshot() []BreakerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]BreakerStatus, 0, len(s.m))
+	for key, b := range s.m {
+		st := BreakerStatus{
+			Key:                 key,
+			State:               b.state,
+			ConsecutiveFailures: b.failures,
+			RecentTotal:         b.n,
+			LastError:           b.lastErr,
+		}
+		for i := 0; i < b.n; i++ {
+			if b.window[i] {
+				st.RecentSuccesses++
+			}
+		}
+		if b.state == BreakerOpen {
+			st.RetryAt = b.openedAt.Add(s.cooldown())
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
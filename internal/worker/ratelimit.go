@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"sync"
+	"time"
+)
+
+// KeyedLimiter rate-limits an expensive action per key (e.g. per chat): at
+// most Limit starts per sliding Window, and at most one run in flight per key.
+// The zero value allows 3 starts per hour.
+type KeyedLimiter struct {
+	Limit  int
+	Window time.Duration
+
+	mu     sync.Mutex
+	starts map[string][]time.Time
+	active map[string]bool
+}
+
+func (l *KeyedLimiter) limit() int {
+	if l.Limit <= 0 {
+		return 3
+	}
+	return l.Limit
+}
+
+func (l *KeyedLimiter) window() time.Duration {
+	if l.Window <= 0 {
+		return time.Hour
+	}
+	return l.Window
+}
+
+// Allow records a start for key when it is under its rate and reports true;
+// otherwise it reports false and how long until the next start is allowed.
+func (l *KeyedLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.starts == nil {
+		l.starts = make(map[string][]time.Time)
+	}
+	now := time.Now()
+	recent := l.starts[key][:0]
+	for _, t := range l.starts[key] {
+		if now.Sub(t) < l.window() {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= l.limit() {
+		l.starts[key] = recent
+		return false, l.window() - now.Sub(recent[0])
+	}
+	l.starts[key] = append(recent, now)
+	return true, 0
+}
+
+// Begin marks key as running; false when a run is already in flight.
+func (l *KeyedLimiter) Begin(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active == nil {
+		l.active = make(map[string]bool)
+	}
+	if l.active[key] {
+		return false
+	}
+	l.active[key] = true
+	return true
+}
+
+// End clears the in-flight mark set by Begin.
+func (l *KeyedLimiter) End(key string) {
+	l.mu.Lock()
+	delete(l.active, key)
+	l.mu.Unlock()
+}
@@ -1,5 +1,7 @@
 package worker
 
+import "context"
+
 type Semaphore struct {
 	ch chan struct{}
 }
@@ -14,3 +16,13 @@ func NewSemaphore(max int) *Semaphore {
 func (s *Semaphore) Acquire() { s.ch <- struct{}{} }
 func (s *Semaphore) Release() { <-s.ch }
 
+// AcquireContext is Acquire that gives up when ctx is done, so a job that times
+// out while queued doesn't later take a slot it no longer needs.
+func (s *Semaphore) AcquireContext(ctx context.Context) error {
+	select {
+	case s.ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -4,12 +4,15 @@ package main
 import (
 	"context"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,6 +22,8 @@ import (
 	"telegram_bot_downloader/internal/cache"
 	"telegram_bot_downloader/internal/downloader"
 	"telegram_bot_downloader/internal/fidcache"
+	"telegram_bot_downloader/internal/jobs"
+	"telegram_bot_downloader/internal/media"
 	"telegram_bot_downloader/internal/platforms"
 	"telegram_bot_downloader/internal/urlx"
 	"telegram_bot_downloader/internal/worker"
@@ -33,6 +38,13 @@ const (
 // Tune based on your CPU + bandwidth. 8 is a good default on most servers.
 const maxConcurrentDownloads = 8
 
+// The disk cache keeps recent downloads for instant re-sends when a cached
+// file_id is rejected; it evicts least recently used entries beyond the budget.
+const (
+	diskCacheMaxBytes = 2 << 30
+	diskCacheMaxAge   = 24 * time.Hour
+)
+
 var linkURLRe = regexp.MustCompile(`https?://\S+`)
 
 // fidCache maps a link to the Telegram file_id(s) of media already uploaded for
@@ -40,6 +52,32 @@ var linkURLRe = regexp.MustCompile(`https?://\S+`)
 // nothing on disk.
 var fidCache = fidcache.New(5000)
 
+// contentIndex maps a download's content fingerprint (fidcache.ContentKey) to
+// the file_ids it was uploaded as, so the same post shared under another URL
+// form (short link, /t/ link, ...) is re-sent instead of uploaded again.
+var contentIndex = fidcache.New(5000)
+
+// jobManager keeps the recent jobs' lifecycle for the /jobs endpoint.
+var jobManager = jobs.NewManager(500)
+
+// engineBreakers skips an engine on a platform for a cooldown once it fails 5
+// times in a row (e.g. Instagram rotating igPolarisPostDocID), then lets one
+// probe through. State: GET /breakers, or /breakers in chat for ADMIN_IDS.
+var engineBreakers = &worker.BreakerSet{
+	Threshold: 5,
+	Cooldown:  5 * time.Minute,
+	OnChange: func(key string, from, to worker.BreakerState) {
+		log.Printf("[breaker] %s %s -> %s", key, from, to)
+	},
+}
+
+// metaDetector looks up a link's title / uploader / thumbnail over oEmbed (no
+// yt-dlp); see lookupMeta.
+var metaDetector downloader.NativeDetector
+
+// metaDetectTimeout bounds that lookup.
+const metaDetectTimeout = 3 * time.Second
+
 /* ================= MAIN ================= */
 
 func main() {
@@ -55,21 +93,52 @@ func main() {
 	ensureCookiesFileFromEnv("TWITTER_COOKIES_B64", "twitter.txt")
 	ensureCookiesFileFromEnv("FACEBOOK_COOKIES_B64", "facebook.txt")
 	ensureCookiesFileFromEnv("PINTEREST_COOKIES_B64", "pinterest.txt")
+	ensureCookiesFileFromEnv("REDDIT_COOKIES_B64", "reddit.txt")
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
+	// Engine success/latency scores survive restarts (adaptive engine ordering).
+	scores := &platforms.Scoreboard{}
+	scoresPath := filepath.Join(downloadsDir, "engine_scores.json")
+	if err := scores.Load(scoresPath); err != nil {
+		log.Printf("[rank] load scores failed: %v", err)
+	}
+	_ = scores.StartAutosave(scoresPath, time.Minute, func(err error) {
+		log.Printf("[rank] save scores failed: %v", err)
+	})
+
+	// Instagram / Facebook oEmbed need a Graph API token ("app_id|client_token");
+	// without one their jobs go without a title.
+	metaDetector = downloader.NativeDetector{FacebookToken: os.Getenv("FB_OEMBED_TOKEN")}
+	// The bot routes jobs with heuristicInfo; this chain (with the OpenGraph
+	// and yt-dlp fallbacks) only serves callers that leave detection to the
+	// pipeline (Download / Detect).
+	detector := metaDetector
+	detector.Fallback = downloader.YtDlpDetector{Cmd: "yt-dlp"}
+
+	// Bounded disk cache: evicted in the background by age and LRU order.
+	diskCache := cache.New(filepath.Join(downloadsDir, "cache"), diskCacheMaxBytes, diskCacheMaxAge)
+	_ = diskCache.StartEvictor(10 * time.Minute)
+
 	dl := &downloader.PipelineDownloader{
-		Detector: downloader.YtDlpDetector{Cmd: "yt-dlp"},
+		Detector: detector,
 		Registry: platforms.DefaultRegistry(),
-		// Cache disabled (Root left empty): media is deleted right after it is
-		// sent, so nothing is kept on disk. (Enable via CacheRootDefault() if
-		// instant re-sends of identical links ever become worth the disk.)
-		Cache:         cache.FileCache{Root: ""},
+		Cache:         diskCache,
 		Semaphore:     worker.NewSemaphore(maxConcurrentDownloads),
+		Breakers:      engineBreakers,
+		Scores:        scores,
 		DownloadsRoot: downloadsDir,
+		PostProcess: []downloader.PostStep{
+			{Processor: downloader.NormalizeFormats{}},
+			{Processor: downloader.StripMetadata{}},
+			{Processor: downloader.Faststart{}},
+			// Engines name files after post IDs / titles / CDN paths; give
+			// documents a clean name instead.
+			{Processor: downloader.RenameFiles{}, Platforms: []string{"instagram", "tiktok", "twitter", "facebook", "pinterest", "threads", "reddit"}},
+		},
 	}
 	if err := dl.EnsureDirs(); err != nil {
 		log.Fatal(err)
@@ -99,6 +168,13 @@ func main() {
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte("OK"))
 		})
+		// Job history (JSON) for support: /jobs and /jobs/{id}, only when
+		// JOBS_TOKEN is set (Bearer header or ?token=).
+		jobsToken := strings.TrimSpace(os.Getenv("JOBS_TOKEN"))
+		jobManager.Register(http.DefaultServeMux, jobsToken)
+		http.HandleFunc("GET /breakers", jobs.RequireToken(jobsToken, func(w http.ResponseWriter, _ *http.Request) {
+			jobs.WriteJSON(w, engineBreakers.Snapshot())
+		}))
 		if err := http.ListenAndServe(":"+port, nil); err != nil {
 			log.Printf("health server stopped: %v", err)
 		}
@@ -116,6 +192,9 @@ func main() {
 		if update.Message != nil {
 			go handleMessage(bot, dl, update.Message)
 		}
+		if update.CallbackQuery != nil {
+			go handleCallback(bot, dl, update.CallbackQuery)
+		}
 	}
 }
 
@@ -143,16 +222,9 @@ func ensureCookiesFileFromEnv(envVar string, targetPath string) {
 /* ================= MESSAGE HANDLER ================= */
 
 func handleMessage(bot *tgbotapi.BotAPI, dl *downloader.PipelineDownloader, msg *tgbotapi.Message) {
-	chatID := msg.Chat.ID
 	text := strings.TrimSpace(msg.Text)
 
-	if text == "/start" {
-		if _, err := bot.Send(tgbotapi.NewMessage(
-			chatID,
-			"👋 Salom!\n\nInstagram, TikTok, X, Facebook yoki Pinterest link yuboring.\nVideo va rasmlarni **eng mos va ochiladigan formatda** yuklab beraman 🚀",
-		)); err != nil {
-			log.Printf("[send] chat_id=%d err=%v", chatID, err)
-		}
+	if handleCommand(bot, dl, msg, text) {
 		return
 	}
 
@@ -162,83 +234,183 @@ func handleMessage(bot *tgbotapi.BotAPI, dl *downloader.PipelineDownloader, msg
 	}
 
 	for _, link := range links {
-		// YouTube isn't supported and we stay silent for it — no reply at all.
-		// Skip the link so a YouTube-only message produces no response, while any
-		// other supported links in the same message are still handled.
-		if urlx.PlatformFromURL(link) == "youtube" {
-			continue
-		}
+		handleLink(bot, dl, msg, link)
+	}
+}
 
-		key := cacheKeyForURL(link)
+// handleLink routes one link from a message (or a /start deep link): profile
+// links get the confirmation keyboard, anything else is downloaded.
+func handleLink(bot *tgbotapi.BotAPI, dl *downloader.PipelineDownloader, msg *tgbotapi.Message, link string) {
+	// YouTube isn't supported and we stay silent for it — no reply at all, so
+	// a YouTube-only message produces no response, while any other supported
+	// links in the same message are still handled.
+	if urlx.PlatformFromURL(link) == "youtube" {
+		return
+	}
+	if prof, ok := isProfileLink(dl, link); ok {
+		handleProfileLink(bot, dl, msg, prof)
+		return
+	}
+	processLink(bot, dl, msg, link)
+}
 
-		// Fast path: this link was uploaded before -> re-send by Telegram file_id.
-		// No download, no re-upload, no loading message => ~1s, and nothing on disk.
-		if items, ok := fidCache.Get(key); ok {
-			if sendCachedAll(bot, chatID, items, msg.MessageID) {
-				log.Printf("[cache] file_id hit url=%q files=%d", link, len(items))
-				continue
-			}
-			fidCache.Delete(key) // stale file_id(s) -> fall through to a fresh fetch
+// processLink downloads one link and sends the media back as a reply to msg.
+func processLink(bot *tgbotapi.BotAPI, dl *downloader.PipelineDownloader, msg *tgbotapi.Message, link string) {
+	chatID := msg.Chat.ID
+	key := cacheKeyForURL(link)
+
+	// Fast path: this link was uploaded before -> re-send by Telegram file_id.
+	// No download, no re-upload, no loading message => ~1s, and nothing on disk.
+	if items, ok := fidCache.Get(key); ok {
+		if sendCachedAll(bot, chatID, items, msg.MessageID) {
+			log.Printf("[cache] file_id hit url=%q files=%d", link, len(items))
+			return
 		}
+		fidCache.Delete(key) // stale file_id(s) -> fall through to a fresh fetch
+	}
 
-		// Cold path: show a loading indicator, but send it CONCURRENTLY so the
-		// download starts immediately instead of blocking on the Telegram round-trip.
-		loading := startLoading(bot, chatID)
-
-		// Heuristic platform/type avoids an expensive yt-dlp --dump-json probe.
-		info := heuristicInfo(link)
+	// Cold path: show a loading indicator, but send it CONCURRENTLY so the
+	// download starts immediately instead of blocking on the Telegram round-trip.
+	loading := startLoading(bot, chatID)
 
-		jobID, jobDir, jerr := downloader.NewJobDir(downloadsDir)
-		if jerr != nil {
-			bot.Send(tgbotapi.NewMessage(chatID, "❌ Yuklab bo‘lmadi"))
-			loading.delete(bot, chatID)
-			continue
+	jobID, jobDir, jerr := downloader.NewJobDir(downloadsDir)
+	if jerr != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Yuklab bo‘lmadi"))
+		loading.delete(bot, chatID)
+		return
+	}
+	job := jobManager.Start(jobID, link, chatID)
+
+	// The URL heuristics route the job (no yt-dlp --dump-json probe); the
+	// title / thumbnail lookup runs alongside the download.
+	info := heuristicInfo(link)
+	job.SetInfo(info.Platform, info.Type)
+	lookupMeta(job, jobID, link)
+	job.SetState(jobs.StateQueued)
+
+	// Overall job timeout for yt-dlp / instaloader.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx = downloader.ContextWithJobLogger(ctx, func(format string, args ...any) {
+		log.Printf("["+jobID+"] "+format, args...)
+	})
+	ctx = downloader.ContextWithJobEvents(ctx, job)
+
+	start := time.Now()
+	res, derr := dl.DownloadWithInfo(ctx, link, jobDir, info)
+	log.Printf("[%s] download_time=%s", jobID, time.Since(start).Truncate(10*time.Millisecond))
+	cancel()
+
+	if derr != nil || res == nil || len(res.Files) == 0 {
+		if derr != nil {
+			log.Printf("[%s] download_failed url=%q err=%v", jobID, link, derr)
+		} else {
+			log.Printf("[%s] download_failed url=%q (empty result)", jobID, link)
 		}
-		// Overall job timeout for yt-dlp / instaloader.
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-		ctx = downloader.ContextWithJobLogger(ctx, func(format string, args ...any) {
-			log.Printf("["+jobID+"] "+format, args...)
-		})
-
-		start := time.Now()
-		res, derr := dl.DownloadWithInfo(ctx, link, jobDir, info)
-		log.Printf("[%s] download_time=%s", jobID, time.Since(start).Truncate(10*time.Millisecond))
-		cancel()
+		msgText := "❌ Yuklab bo‘lmadi"
+		if derr == downloader.ErrPrivate {
+			msgText = "🔒 Bu kontent private (login kerak bo‘lishi mumkin)."
+		} else if derr == downloader.ErrNotFound {
+			msgText = "❌ Kontent topilmadi yoki o‘chirib yuborilgan."
+		} else if errors.Is(derr, downloader.ErrNoMedia) {
+			msgText = "📝 Bu postda rasm yoki video yo‘q — faqat matn."
+		} else if errors.Is(derr, downloader.ErrLoginRequired) {
+			msgText = "🔒 Bu kontentni ko‘rish uchun login kerak (botda Instagram sessiyasi yo‘q yoki eskirgan)."
+		}
+		if derr == nil {
+			derr = errors.New("empty result")
+		}
+		job.Fail(derr)
+		bot.Send(tgbotapi.NewMessage(chatID, msgText))
+		_ = os.RemoveAll(jobDir)
+		loading.delete(bot, chatID)
+		return
+	}
 
-		if derr != nil || res == nil || len(res.Files) == 0 {
-			if derr != nil {
-				log.Printf("[%s] download_failed url=%q err=%v", jobID, link, derr)
-			} else {
-				log.Printf("[%s] download_failed url=%q (empty result)", jobID, link)
-			}
-			msgText := "❌ Yuklab bo‘lmadi"
-			if derr == downloader.ErrPrivate {
-				msgText = "🔒 Bu kontent private (login kerak bo‘lishi mumkin)."
-			} else if derr == downloader.ErrNotFound {
-				msgText = "❌ Kontent topilmadi yoki o‘chirib yuborilgan."
-			}
-			bot.Send(tgbotapi.NewMessage(chatID, msgText))
+	log.Printf("[%s] engine=%s attempts=%d items=%d elapsed=%s", jobID, res.Engine, len(res.Attempts), len(res.Items), res.Elapsed.Truncate(10*time.Millisecond))
+	job.SetState(jobs.StateUploading)
+
+	// Same content already uploaded from a different URL: re-send its file_ids
+	// and link this URL to them.
+	contentKey, herr := fidcache.ContentKey(res.Files)
+	if herr != nil {
+		log.Printf("[%s] content_hash err=%v", jobID, herr)
+	} else if items, ok := contentIndex.Get(contentKey); ok {
+		if sendCachedAll(bot, chatID, items, msg.MessageID) {
+			log.Printf("[%s] content hit url=%q files=%d", jobID, link, len(items))
+			job.Done(len(items))
+			fidCache.Put(key, items)
 			_ = os.RemoveAll(jobDir)
 			loading.delete(bot, chatID)
-			continue
+			return
 		}
+		contentIndex.Delete(contentKey)
+	}
 
-		sendStart := time.Now()
-		var captured []fidcache.Item
-		for _, f := range res.Files {
-			if kind, fid := sendMedia(bot, chatID, f, msg.MessageID); fid != "" {
+	sendStart := time.Now()
+	var captured []fidcache.Item
+	if isSlideshow(info, res.Items) {
+		captured = sendSlideshow(bot, chatID, res.Items, msg.MessageID)
+	} else {
+		for _, item := range res.Items {
+			if kind, fid := sendMedia(bot, chatID, item, msg.MessageID); fid != "" {
 				captured = append(captured, fidcache.Item{Kind: kind, FileID: fid})
 			}
 		}
-		log.Printf("[%s] send_time=%s files=%d", jobID, time.Since(sendStart).Truncate(10*time.Millisecond), len(res.Files))
+	}
+	log.Printf("[%s] send_time=%s files=%d", jobID, time.Since(sendStart).Truncate(10*time.Millisecond), len(res.Files))
 
+	if len(captured) == 0 {
+		job.Fail(errors.New("telegram rejected every file"))
+	} else {
+		job.Done(len(captured))
+	}
+
+	if len(res.Missing) > 0 {
+		// Partial carousel: say what's missing, and don't cache it so the
+		// next request for this link tries the missing items again.
+		note := tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ Postdagi %d ta fayl yuklab bo‘lmadi (%s-o‘rin).", len(res.Missing), joinPositions(res.Missing)))
+		note.ReplyToMessageID = msg.MessageID
+		bot.Send(note)
+	} else if len(captured) == len(res.Files) {
 		// Cache the file_ids so the next request for this link is instant.
+		// A partial send isn't cached either: a hit would replay it as the
+		// whole post.
 		fidCache.Put(key, captured)
+		if herr == nil {
+			contentIndex.Put(contentKey, captured)
+		}
+	}
 
-		// Free disk immediately: media is sent, nothing is kept on disk.
-		_ = os.RemoveAll(jobDir)
-		loading.delete(bot, chatID)
+	// Free the job dir immediately: media is sent, and the disk cache linked
+	// its own copy aside before saving it in the background.
+	_ = os.RemoveAll(jobDir)
+	loading.delete(bot, chatID)
+}
+
+// joinPositions formats 1-based post positions for a user message: "2, 7".
+func joinPositions(positions []int) string {
+	parts := make([]string, len(positions))
+	for i, n := range positions {
+		parts[i] = strconv.Itoa(n)
 	}
+	return strings.Join(parts, ", ")
+}
+
+// lookupMeta fills the job's title / uploader / thumbnail, shown only in the
+// job status, over oEmbed in the background while the download runs. The page
+// itself isn't fetched: every strategy routes on the URL heuristics alone, and
+// for Instagram a page GET would be one more instagram.com hit per job.
+func lookupMeta(job *jobs.Job, jobID, link string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), metaDetectTimeout)
+		defer cancel()
+		meta, err := metaDetector.DetectOEmbed(ctx, link)
+		if err != nil {
+			log.Printf("[%s] metadata err=%v", jobID, err)
+			return
+		}
+		job.SetMeta(meta.Title, meta.Uploader, meta.Thumbnail)
+	}()
 }
 
 func heuristicInfo(rawURL string) *downloader.MediaInfo {
@@ -248,6 +420,8 @@ func heuristicInfo(rawURL string) *downloader.MediaInfo {
 	// Instagram has clear URL shapes.
 	if plat == "instagram" {
 		switch {
+		case platforms.IsInstagramStoryURL(rawURL):
+			typ = "story"
 		case strings.Contains(u, "/reel/") || strings.Contains(u, "/tv/"):
 			typ = "video"
 		case strings.Contains(u, "/p/"):
@@ -256,6 +430,10 @@ func heuristicInfo(rawURL string) *downloader.MediaInfo {
 			typ = "carousel"
 		}
 	}
+	// TikTok photo posts (image carousel + soundtrack) have their own engine.
+	if plat == "tiktok" && strings.Contains(u, "/photo/") {
+		typ = "slideshow"
+	}
 	if plat == "facebook" {
 		// Tag the type so yt-dlp picks a video vs. permissive (photo) format pass;
 		// Facebook runs on yt-dlp only (no gallery-dl).
@@ -298,17 +476,25 @@ func isSupported(u string) bool {
 		strings.Contains(u, "facebook") ||
 		strings.Contains(u, "fb.watch") ||
 		strings.Contains(u, "pinterest") ||
+		strings.Contains(u, "threads.net") ||
+		strings.Contains(u, "threads.com") ||
+		strings.Contains(u, "reddit.com") ||
+		strings.Contains(u, "redd.it") ||
 		strings.Contains(u, "pin.it")
 }
 
-// isVideoFile reports whether a downloaded file is a video (sent as a Telegram
-// video with an inline player) vs an image (sent as a photo). Detected per file
-// so a mixed carousel sends each item with the correct type.
-func isVideoFile(file string) bool {
-	switch strings.ToLower(filepath.Ext(file)) {
-	case ".mp4", ".mov", ".webm", ".mkv", ".avi", ".m4v":
+// isSlideshow reports whether a result should be delivered as an album plus its
+// soundtrack: a known TikTok photo post, or any result that came with a
+// separate audio track (short links don't reveal the type up front).
+func isSlideshow(info *downloader.MediaInfo, items []downloader.MediaFile) bool {
+	if info != nil && info.Type == "slideshow" {
 		return true
 	}
+	for _, item := range items {
+		if item.Kind == "audio" {
+			return true
+		}
+	}
 	return false
 }
 
@@ -342,11 +528,37 @@ func cacheKeyForURL(raw string) string {
 
 // sendMedia uploads a downloaded file and returns the Telegram kind + file_id of
 // the resulting message (empty on failure) so the link can be cached for instant
-// re-sends.
-func sendMedia(bot *tgbotapi.BotAPI, chatID int64, file string, replyTo int) (kind, fileID string) {
+// re-sends. The item's Kind (sniffed by the pipeline) decides how it's sent, so
+// a mixed carousel sends each file with the right type.
+func sendMedia(bot *tgbotapi.BotAPI, chatID int64, item downloader.MediaFile, replyTo int) (kind, fileID string) {
+	file := item.Path
 	caption := "⬇️ @downloaderin123_bot"
 
-	if isVideoFile(file) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	// GIF / animated WebP (Pinterest): convert to a silent MP4 and send it as an
+	// animation — as a photo Telegram shows only the first frame (or rejects it).
+	if media.IsGIF(file) || media.IsAnimatedWebP(file) {
+		src := file
+		if mp4, err := media.ToAnimationMP4(ctx, file); err == nil {
+			src = mp4
+		} else {
+			log.Printf("[send] animation convert file=%q err=%v", file, err)
+			if !media.IsGIF(file) {
+				// Telegram takes GIFs as-is, but not WebP: keep the original intact.
+				return sendDocument(bot, chatID, file, caption, replyTo)
+			}
+		}
+		return sendAnimation(bot, chatID, src, caption, replyTo)
+	}
+
+	if item.Kind == "video" {
+		// X/Twitter and Reddit "GIFs" are silent MP4s the engine marks as
+		// animations: send them looping instead of as a video with a player.
+		if item.Animation {
+			return sendAnimation(bot, chatID, file, caption, replyTo)
+		}
 		v := tgbotapi.NewVideo(chatID, tgbotapi.FilePath(file))
 		v.Caption = caption
 		v.SupportsStreaming = true
@@ -361,12 +573,149 @@ func sendMedia(bot *tgbotapi.BotAPI, chatID int64, file string, replyTo int) (ki
 		return classifyMedia(m)
 	}
 
-	p := tgbotapi.NewPhoto(chatID, tgbotapi.FilePath(file))
+	return sendPhoto(bot, chatID, file, caption, replyTo)
+}
+
+// sendPhoto sends an image as a photo when it fits (or can be shrunk to fit)
+// Telegram's photo limits, else as a document so its full resolution is kept.
+// The returned kind says which one happened, so the file_id cache re-sends it
+// the same way.
+func sendPhoto(bot *tgbotapi.BotAPI, chatID int64, file, caption string, replyTo int) (kind, fileID string) {
+	fit, asPhoto, err := media.FitPhoto(file)
+	if err != nil {
+		log.Printf("[send] fit photo file=%q err=%v", file, err)
+	}
+	if !asPhoto {
+		return sendDocument(bot, chatID, file, caption, replyTo)
+	}
+	p := tgbotapi.NewPhoto(chatID, tgbotapi.FilePath(fit))
 	p.Caption = caption
 	p.ReplyToMessageID = replyTo
 	m, err := bot.Send(p)
 	if err != nil {
-		log.Printf("[send] photo chat_id=%d err=%v", chatID, err)
+		// e.g. PHOTO_INVALID_DIMENSIONS for a format we couldn't measure.
+		log.Printf("[send] photo chat_id=%d err=%v; retrying as document", chatID, err)
+		return sendDocument(bot, chatID, file, caption, replyTo)
+	}
+	return classifyMedia(m)
+}
+
+// maxAlbumSize is Telegram's media group limit.
+const maxAlbumSize = 10
+
+// sendSlideshow delivers a photo post: the slides as album(s), then the
+// soundtrack via sendAudio, then any rendered video. Returns the captured
+// file_ids in send order, slides marked Album so cache hits regroup them.
+func sendSlideshow(bot *tgbotapi.BotAPI, chatID int64, items []downloader.MediaFile, replyTo int) []fidcache.Item {
+	caption := "⬇️ @downloaderin123_bot"
+
+	// Slides past Telegram's photo limits (and not fixable by shrinking) can't
+	// join an album; they follow as documents.
+	var photos, docs []string
+	var rest []downloader.MediaFile
+	for _, item := range items {
+		if item.Kind == "video" || item.Kind == "audio" {
+			rest = append(rest, item)
+			continue
+		}
+		fit, asPhoto, err := media.FitPhoto(item.Path)
+		if err != nil {
+			log.Printf("[send] fit photo file=%q err=%v", item.Path, err)
+		}
+		if asPhoto {
+			photos = append(photos, fit)
+		} else {
+			docs = append(docs, item.Path)
+		}
+	}
+
+	var captured []fidcache.Item
+	for start := 0; start < len(photos); start += maxAlbumSize {
+		end := min(start+maxAlbumSize, len(photos))
+		var refs []tgbotapi.RequestFileData
+		for _, f := range photos[start:end] {
+			refs = append(refs, tgbotapi.FilePath(f))
+		}
+		items, err := sendAlbum(bot, chatID, refs, caption, replyTo)
+		if err != nil {
+			log.Printf("[send] album chat_id=%d err=%v", chatID, err)
+			continue
+		}
+		captured = append(captured, items...)
+	}
+	for _, f := range docs {
+		if kind, fid := sendDocument(bot, chatID, f, caption, replyTo); fid != "" {
+			captured = append(captured, fidcache.Item{Kind: kind, FileID: fid})
+		}
+	}
+
+	for _, item := range rest {
+		if item.Kind == "audio" {
+			a := tgbotapi.NewAudio(chatID, tgbotapi.FilePath(item.Path))
+			a.Caption = caption
+			a.ReplyToMessageID = replyTo
+			m, err := bot.Send(a)
+			if err != nil {
+				log.Printf("[send] audio chat_id=%d err=%v", chatID, err)
+				continue
+			}
+			if kind, fid := classifyMedia(m); fid != "" {
+				captured = append(captured, fidcache.Item{Kind: kind, FileID: fid})
+			}
+			continue
+		}
+		if kind, fid := sendMedia(bot, chatID, item, replyTo); fid != "" {
+			captured = append(captured, fidcache.Item{Kind: kind, FileID: fid})
+		}
+	}
+	return captured
+}
+
+// sendAlbum sends up to maxAlbumSize photos as one media group (caption on the
+// first) and returns their file_ids marked as album items.
+func sendAlbum(bot *tgbotapi.BotAPI, chatID int64, refs []tgbotapi.RequestFileData, caption string, replyTo int) ([]fidcache.Item, error) {
+	group := make([]interface{}, 0, len(refs))
+	for i, ref := range refs {
+		p := tgbotapi.NewInputMediaPhoto(ref)
+		if i == 0 {
+			p.Caption = caption
+		}
+		group = append(group, p)
+	}
+	cfg := tgbotapi.NewMediaGroup(chatID, group)
+	cfg.ReplyToMessageID = replyTo
+	msgs, err := bot.SendMediaGroup(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var items []fidcache.Item
+	for _, m := range msgs {
+		if kind, fid := classifyMedia(m); fid != "" {
+			items = append(items, fidcache.Item{Kind: kind, FileID: fid, Album: true})
+		}
+	}
+	return items, nil
+}
+
+func sendAnimation(bot *tgbotapi.BotAPI, chatID int64, file, caption string, replyTo int) (kind, fileID string) {
+	a := tgbotapi.NewAnimation(chatID, tgbotapi.FilePath(file))
+	a.Caption = caption
+	a.ReplyToMessageID = replyTo
+	m, err := bot.Send(a)
+	if err != nil {
+		log.Printf("[send] animation chat_id=%d err=%v", chatID, err)
+		return "", ""
+	}
+	return classifyMedia(m)
+}
+
+func sendDocument(bot *tgbotapi.BotAPI, chatID int64, file, caption string, replyTo int) (kind, fileID string) {
+	d := tgbotapi.NewDocument(chatID, tgbotapi.FilePath(file))
+	d.Caption = caption
+	d.ReplyToMessageID = replyTo
+	m, err := bot.Send(d)
+	if err != nil {
+		log.Printf("[send] document chat_id=%d err=%v", chatID, err)
 		return "", ""
 	}
 	return classifyMedia(m)
@@ -379,6 +728,8 @@ func classifyMedia(m tgbotapi.Message) (kind, fileID string) {
 		return "video", m.Video.FileID
 	case m.Animation != nil:
 		return "animation", m.Animation.FileID
+	case m.Audio != nil:
+		return "audio", m.Audio.FileID
 	case m.Document != nil:
 		return "document", m.Document.FileID
 	case len(m.Photo) > 0:
@@ -391,14 +742,30 @@ func classifyMedia(m tgbotapi.Message) (kind, fileID string) {
 // when nothing was sent (stale first file_id), so the caller can re-download
 // without producing duplicates.
 func sendCachedAll(bot *tgbotapi.BotAPI, chatID int64, items []fidcache.Item, replyTo int) bool {
-	for i, it := range items {
-		if err := sendByFileID(bot, chatID, it, replyTo); err != nil {
+	for i := 0; i < len(items); {
+		// Consecutive album items (slideshow slides) go back out as one group.
+		n := 1
+		var err error
+		if items[i].Album {
+			for n < maxAlbumSize && i+n < len(items) && items[i+n].Album {
+				n++
+			}
+			refs := make([]tgbotapi.RequestFileData, 0, n)
+			for _, it := range items[i : i+n] {
+				refs = append(refs, tgbotapi.FileID(it.FileID))
+			}
+			_, err = sendAlbum(bot, chatID, refs, "⬇️ @downloaderin123_bot", replyTo)
+		} else {
+			err = sendByFileID(bot, chatID, items[i], replyTo)
+		}
+		if err != nil {
 			log.Printf("[cache] file_id send failed (item %d): %v", i, err)
 			if i == 0 {
 				return false // nothing sent yet -> safe to re-download
 			}
 			return true // partial send already happened; don't duplicate
 		}
+		i += n
 	}
 	return true
 }
@@ -421,6 +788,11 @@ func sendByFileID(bot *tgbotapi.BotAPI, chatID int64, it fidcache.Item, replyTo
 		a.Caption = caption
 		a.ReplyToMessageID = replyTo
 		c = a
+	case "audio":
+		a := tgbotapi.NewAudio(chatID, ref)
+		a.Caption = caption
+		a.ReplyToMessageID = replyTo
+		c = a
 	case "document":
 		d := tgbotapi.NewDocument(chatID, ref)
 		d.Caption = caption
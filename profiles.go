@@ -0,0 +1,445 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram_bot_downloader/internal/downloader"
+	"telegram_bot_downloader/internal/fidcache"
+	"telegram_bot_downloader/internal/jobs"
+	"telegram_bot_downloader/internal/media"
+	"telegram_bot_downloader/internal/platforms"
+	"telegram_bot_downloader/internal/urlx"
+	"telegram_bot_downloader/internal/worker"
+)
+
+/* ================= PROFILE DOWNLOADS ================= */
+
+// A profile link (instagram.com/<user>/, tiktok.com/@user, x.com/<user>/media)
+// lists the account's latest posts, asks how many to fetch (inline keyboard),
+// then downloads them through the pipeline a batch at a time and sends each
+// batch as albums while a status message shows the progress.
+const (
+	profileDefaultMaxItems = 20 // per request; env PROFILE_MAX_ITEMS
+	profileBatchSize       = 5  // posts downloaded before a batch is sent
+	profileConfirmTTL      = 10 * time.Minute
+
+	// Profile jobs download one post at a time and at most this many run at
+	// once, so they hold at most this many of the maxConcurrentDownloads
+	// slots and single links keep flowing.
+	maxConcurrentProfileJobs = 2
+
+	// Telegram's upload limit for bots; bigger videos can't join an album.
+	albumVideoMaxBytes = 50 << 20
+)
+
+var (
+	profileSlots = worker.NewSemaphore(maxConcurrentProfileJobs)
+
+	// profileLimiter: per chat, 3 profile requests an hour and one at a time.
+	profileLimiter = &worker.KeyedLimiter{Limit: 3, Window: time.Hour}
+
+	pendingProfiles = &profileRequests{m: map[string]*profileRequest{}}
+)
+
+// profileMaxItems is the per-request cap on posts (PROFILE_MAX_ITEMS).
+func profileMaxItems() int {
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("PROFILE_MAX_ITEMS"))); err == nil && n > 0 {
+		return n
+	}
+	return profileDefaultMaxItems
+}
+
+// profileRequest is a listed profile waiting for the user's confirmation.
+type profileRequest struct {
+	profile urlx.Profile
+	urls    []string
+	chatID  int64
+	userID  int64
+	replyTo int
+	created time.Time
+}
+
+type profileRequests struct {
+	mu sync.Mutex
+	m  map[string]*profileRequest
+}
+
+func (p *profileRequests) put(req *profileRequest) string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	id := hex.EncodeToString(b[:])
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for k, r := range p.m {
+		if time.Since(r.created) > profileConfirmTTL {
+			delete(p.m, k)
+		}
+	}
+	p.m[id] = req
+	return id
+}
+
+// take removes and returns the request if it exists, hasn't expired and
+// belongs to userID.
+func (p *profileRequests) take(id string, userID int64) (*profileRequest, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	req, ok := p.m[id]
+	if !ok || time.Since(req.created) > profileConfirmTTL || req.userID != userID {
+		return nil, false
+	}
+	delete(p.m, id)
+	return req, true
+}
+
+// handleProfileLink lists a profile and asks how many posts to download.
+func handleProfileLink(bot *tgbotapi.BotAPI, dl *downloader.PipelineDownloader, msg *tgbotapi.Message, prof urlx.Profile) {
+	chatID := msg.Chat.ID
+	lister := dl.Registry.ProfileListerFor(prof.Platform)
+	if lister == nil || msg.From == nil {
+		reply(bot, chatID, "❌ Bu platformadan profilni yuklab bo‘lmaydi.")
+		return
+	}
+	if ok, wait := profileLimiter.Allow(strconv.FormatInt(chatID, 10)); !ok {
+		reply(bot, chatID, fmt.Sprintf("⏳ Profil yuklash limiti tugadi. %d daqiqadan keyin urinib ko‘ring.", int(wait.Minutes())+1))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	urls, err := lister.ListProfile(ctx, prof, profileMaxItems())
+	if err != nil {
+		log.Printf("[profile] list platform=%s handle=%s err=%v", prof.Platform, prof.Handle, err)
+		if errors.Is(err, platforms.ErrLoginRequired) {
+			reply(bot, chatID, "🔒 Bu profil yopiq yoki login talab qiladi.")
+		} else {
+			reply(bot, chatID, "❌ Profildagi postlarni o‘qib bo‘lmadi.")
+		}
+		return
+	}
+	log.Printf("[profile] listed platform=%s handle=%s posts=%d", prof.Platform, prof.Handle, len(urls))
+
+	id := pendingProfiles.put(&profileRequest{
+		profile: prof, urls: urls, chatID: chatID, userID: msg.From.ID,
+		replyTo: msg.MessageID, created: time.Now(),
+	})
+	var row []tgbotapi.InlineKeyboardButton
+	for _, n := range profileChoices(len(urls)) {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(strconv.Itoa(n), "pf:"+id+":"+strconv.Itoa(n)))
+	}
+	ask := tgbotapi.NewMessage(chatID, fmt.Sprintf("📂 @%s: oxirgi %d ta post topildi. Nechtasini yuklab olay?", prof.Handle, len(urls)))
+	ask.ReplyToMessageID = msg.MessageID
+	ask.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(row,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("❌ Bekor qilish", "pf:"+id+":x")))
+	if _, err := bot.Send(ask); err != nil {
+		log.Printf("[send] chat_id=%d err=%v", chatID, err)
+	}
+}
+
+// profileChoices offers 5, 10 and everything found (when smaller ones apply).
+func profileChoices(total int) []int {
+	var out []int
+	for _, n := range []int{5, 10} {
+		if n < total {
+			out = append(out, n)
+		}
+	}
+	return append(out, total)
+}
+
+// handleCallback answers inline keyboard presses (profile confirmations).
+func handleCallback(bot *tgbotapi.BotAPI, dl *downloader.PipelineDownloader, cq *tgbotapi.CallbackQuery) {
+	rest, ok := strings.CutPrefix(cq.Data, "pf:")
+	if !ok || cq.Message == nil {
+		_, _ = bot.Request(tgbotapi.NewCallback(cq.ID, ""))
+		return
+	}
+	id, choice, _ := strings.Cut(rest, ":")
+	req, ok := pendingProfiles.take(id, cq.From.ID)
+	if !ok {
+		_, _ = bot.Request(tgbotapi.NewCallback(cq.ID, "So‘rov eskirgan yoki sizniki emas."))
+		return
+	}
+	_, _ = bot.Request(tgbotapi.NewCallback(cq.ID, ""))
+
+	statusID := cq.Message.MessageID
+	if choice == "x" {
+		editStatus(bot, req.chatID, statusID, "❌ Bekor qilindi.")
+		return
+	}
+	n, err := strconv.Atoi(choice)
+	if err != nil || n <= 0 {
+		return
+	}
+	runProfileJob(bot, dl, req, min(n, len(req.urls)), statusID)
+}
+
+// profilePost is one post of a profile job.
+type profilePost struct {
+	url      string
+	key      string // fidCache key
+	cached   []fidcache.Item
+	res      *downloader.DownloadResult
+	job      *jobs.Job
+	jobDir   string
+	captured []fidcache.Item
+	sent     bool
+}
+
+// runProfileJob downloads the first n listed posts in batches and sends them,
+// updating the status message as it goes.
+func runProfileJob(bot *tgbotapi.BotAPI, dl *downloader.PipelineDownloader, req *profileRequest, n int, statusID int) {
+	handle := "@" + req.profile.Handle
+	chatKey := strconv.FormatInt(req.chatID, 10)
+	if !profileLimiter.Begin(chatKey) {
+		editStatus(bot, req.chatID, statusID, "⏳ Avvalgi profil yuklanishi tugashini kuting.")
+		return
+	}
+	defer profileLimiter.End(chatKey)
+
+	editStatus(bot, req.chatID, statusID, fmt.Sprintf("⏳ %s: navbatda...", handle))
+	qctx, qcancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	err := profileSlots.AcquireContext(qctx)
+	qcancel()
+	if err != nil {
+		editStatus(bot, req.chatID, statusID, "❌ Navbat juda uzun, keyinroq urinib ko‘ring.")
+		return
+	}
+	defer profileSlots.Release()
+
+	sent, failed := 0, 0
+	editStatus(bot, req.chatID, statusID, fmt.Sprintf("⏳ %s: 0/%d yuklanmoqda...", handle, n))
+	for start := 0; start < n; start += profileBatchSize {
+		var batch []*profilePost
+		for _, u := range req.urls[start:min(start+profileBatchSize, n)] {
+			batch = append(batch, downloadProfilePost(dl, req.chatID, u))
+		}
+		sendProfileBatch(bot, req.chatID, req.replyTo, batch)
+		for _, p := range batch {
+			if p.sent {
+				sent++
+			} else {
+				failed++
+			}
+			if p.jobDir != "" {
+				_ = os.RemoveAll(p.jobDir)
+			}
+		}
+		editStatus(bot, req.chatID, statusID, fmt.Sprintf("⏳ %s: %d/%d yuklanmoqda...", handle, start+len(batch), n))
+	}
+
+	text := fmt.Sprintf("✅ %s: %d ta post yuborildi.", handle, sent)
+	if failed > 0 {
+		text += fmt.Sprintf(" %d tasini yuklab bo‘lmadi.", failed)
+	}
+	editStatus(bot, req.chatID, statusID, text)
+	log.Printf("[profile] done platform=%s handle=%s sent=%d failed=%d", req.profile.Platform, req.profile.Handle, sent, failed)
+}
+
+// downloadProfilePost fetches one post through the pipeline (or finds its
+// file_ids in fidCache). Failures leave res nil.
+func downloadProfilePost(dl *downloader.PipelineDownloader, chatID int64, link string) *profilePost {
+	p := &profilePost{url: link, key: cacheKeyForURL(link)}
+	if items, ok := fidCache.Get(p.key); ok {
+		p.cached = items
+		return p
+	}
+	jobID, jobDir, err := downloader.NewJobDir(downloadsDir)
+	if err != nil {
+		return p
+	}
+	p.jobDir = jobDir
+	p.job = jobManager.Start(jobID, link, chatID)
+	info := heuristicInfo(link)
+	p.job.SetInfo(info.Platform, info.Type)
+	lookupMeta(p.job, jobID, link)
+	p.job.SetState(jobs.StateQueued)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	ctx = downloader.ContextWithJobLogger(ctx, func(format string, args ...any) {
+		log.Printf("["+jobID+"] "+format, args...)
+	})
+	ctx = downloader.ContextWithJobEvents(ctx, p.job)
+	res, err := dl.DownloadWithInfo(ctx, link, jobDir, info)
+	if err != nil || res == nil || len(res.Files) == 0 {
+		if err == nil {
+			err = errors.New("empty result")
+		}
+		log.Printf("[%s] download_failed url=%q err=%v", jobID, link, err)
+		p.job.Fail(err)
+		return p
+	}
+	p.job.SetState(jobs.StateUploading)
+	p.res = res
+	return p
+}
+
+// albumEntry is a file queued for a mixed media group.
+type albumEntry struct {
+	item  downloader.MediaFile // original file (sent alone when the group has one entry)
+	send  string               // what goes in the group (a photo may be a downscaled copy)
+	video bool
+	post  *profilePost
+}
+
+// sendProfileBatch sends a batch's posts in order: photos and videos as
+// media groups of up to maxAlbumSize, everything else (animations, audio,
+// documents, cached posts) on its own.
+func sendProfileBatch(bot *tgbotapi.BotAPI, chatID int64, replyTo int, batch []*profilePost) {
+	var group []albumEntry
+	flush := func() {
+		sendMixedAlbum(bot, chatID, group, replyTo)
+		group = group[:0]
+	}
+	for _, p := range batch {
+		if p.cached != nil {
+			flush()
+			p.sent = sendCachedAll(bot, chatID, p.cached, replyTo)
+			continue
+		}
+		if p.res == nil {
+			continue
+		}
+		for _, item := range p.res.Items {
+			if e, ok := albumEntryFor(item); ok {
+				e.post = p
+				group = append(group, e)
+				if len(group) == maxAlbumSize {
+					flush()
+				}
+				continue
+			}
+			flush()
+			if kind, fid := sendMedia(bot, chatID, item, replyTo); fid != "" {
+				p.captured = append(p.captured, fidcache.Item{Kind: kind, FileID: fid})
+			}
+		}
+	}
+	flush()
+
+	for _, p := range batch {
+		if p.res == nil {
+			continue
+		}
+		p.sent = len(p.captured) > 0
+		if !p.sent {
+			p.job.Fail(errors.New("telegram rejected every file"))
+			continue
+		}
+		p.job.Done(len(p.captured))
+		if len(p.captured) == len(p.res.Files) && len(p.res.Missing) == 0 {
+			fidCache.Put(p.key, p.captured)
+		}
+	}
+}
+
+// albumEntryFor reports whether a file can go in a media group: a photo that
+// fits Telegram's limits, or a regular video small enough to upload.
+// Animations and audio can't be mixed into a photo/video album.
+func albumEntryFor(item downloader.MediaFile) (albumEntry, bool) {
+	f := item.Path
+	if media.IsGIF(f) || media.IsAnimatedWebP(f) || item.Kind == "audio" {
+		return albumEntry{}, false
+	}
+	if item.Kind == "video" {
+		st, err := os.Stat(f)
+		if err != nil || st.Size() > albumVideoMaxBytes {
+			return albumEntry{}, false
+		}
+		if item.Animation {
+			return albumEntry{}, false
+		}
+		return albumEntry{item: item, send: f, video: true}, true
+	}
+	fit, asPhoto, err := media.FitPhoto(f)
+	if err != nil {
+		log.Printf("[send] fit photo file=%q err=%v", f, err)
+	}
+	if !asPhoto {
+		return albumEntry{}, false
+	}
+	return albumEntry{item: item, send: fit}, true
+}
+
+// sendMixedAlbum sends photos and videos as one media group (a lone entry
+// goes through sendMedia) and records the file_ids on each entry's post. If
+// Telegram rejects the group, the entries are sent one by one instead.
+func sendMixedAlbum(bot *tgbotapi.BotAPI, chatID int64, entries []albumEntry, replyTo int) {
+	sendEach := func() {
+		for _, e := range entries {
+			if kind, fid := sendMedia(bot, chatID, e.item, replyTo); fid != "" {
+				e.post.captured = append(e.post.captured, fidcache.Item{Kind: kind, FileID: fid})
+			}
+		}
+	}
+	switch len(entries) {
+	case 0:
+		return
+	case 1:
+		sendEach()
+		return
+	}
+
+	group := make([]interface{}, 0, len(entries))
+	for i, e := range entries {
+		caption := ""
+		if i == 0 {
+			caption = "⬇️ @downloaderin123_bot"
+		}
+		if e.video {
+			v := tgbotapi.NewInputMediaVideo(tgbotapi.FilePath(e.send))
+			v.Caption = caption
+			v.SupportsStreaming = true
+			group = append(group, v)
+		} else {
+			p := tgbotapi.NewInputMediaPhoto(tgbotapi.FilePath(e.send))
+			p.Caption = caption
+			group = append(group, p)
+		}
+	}
+	cfg := tgbotapi.NewMediaGroup(chatID, group)
+	cfg.ReplyToMessageID = replyTo
+	msgs, err := bot.SendMediaGroup(cfg)
+	if err != nil {
+		log.Printf("[send] media group chat_id=%d err=%v; sending one by one", chatID, err)
+		sendEach()
+		return
+	}
+	for i, m := range msgs {
+		if i >= len(entries) {
+			break
+		}
+		if kind, fid := classifyMedia(m); fid != "" {
+			// Only photo runs are regrouped on a cache re-send (sendAlbum).
+			item := fidcache.Item{Kind: kind, FileID: fid, Album: kind == "photo"}
+			entries[i].post.captured = append(entries[i].post.captured, item)
+		}
+	}
+}
+
+// editStatus replaces a status message's text (and drops its keyboard).
+func editStatus(bot *tgbotapi.BotAPI, chatID int64, messageID int, text string) {
+	if _, err := bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, text)); err != nil && !strings.Contains(err.Error(), "not modified") {
+		log.Printf("[send] edit chat_id=%d err=%v", chatID, err)
+	}
+}
+
+// isProfileLink reports whether link is a profile page this bot can bulk
+// download.
+func isProfileLink(dl *downloader.PipelineDownloader, link string) (urlx.Profile, bool) {
+	prof, ok := urlx.ProfileFromURL(link)
+	return prof, ok && dl.Registry.ProfileListerFor(prof.Platform) != nil
+}